@@ -5,10 +5,14 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Financial-Times/go-fthealth/v1a"
 	"github.com/gorilla/mux"
 	"github.com/jawher/mow.cli"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func init() {
@@ -39,12 +43,78 @@ func main() {
 		Desc:   "s3 domain of factset bucket",
 		EnvVar: "S3_DOMAIN",
 	})
+	resourcesFolder := app.String(cli.StringOpt{
+		Name:   "resources-folder",
+		Desc:   "dated folder to load (e.g. 2017-08-10) instead of following the weekly index file",
+		EnvVar: "RESOURCES_FOLDER",
+	})
+	localDataDir := app.String(cli.StringOpt{
+		Name:   "local-data-dir",
+		Desc:   "local directory to read the factset data from instead of s3, laid out like the bucket",
+		EnvVar: "LOCAL_DATA_DIR",
+	})
 	baseUrl := app.String(cli.StringOpt{
 		Name:   "base-url",
 		Value:  "http://localhost:8080/transformers/financial-instruments/",
 		Desc:   "Base url",
 		EnvVar: "BASE_URL",
 	})
+	refreshInterval := app.String(cli.StringOpt{
+		Name:   "refresh-interval",
+		Value:  "1h",
+		Desc:   "how often to check the weekly index for a new dataset, e.g. 30m or 1h (0 disables the refresh)",
+		EnvVar: "REFRESH_INTERVAL",
+	})
+	initRetryInterval := app.String(cli.StringOpt{
+		Name:   "init-retry-interval",
+		Value:  "5s",
+		Desc:   "wait before retrying a failed initial load; doubled after every failure",
+		EnvVar: "INIT_RETRY_INTERVAL",
+	})
+	initRetryMaxInterval := app.String(cli.StringOpt{
+		Name:   "init-retry-max-interval",
+		Value:  "5m",
+		Desc:   "maximum wait between two attempts of the initial load",
+		EnvVar: "INIT_RETRY_MAX_INTERVAL",
+	})
+	initDeadline := app.String(cli.StringOpt{
+		Name:   "init-deadline",
+		Value:  "1h",
+		Desc:   "how long to keep retrying the initial load before giving up",
+		EnvVar: "INIT_DEADLINE",
+	})
+	snapshotFile := app.String(cli.StringOpt{
+		Name:   "snapshot-file",
+		Desc:   "local file to persist the last transformed dataset to, so it can be served straight away on restart (disabled when empty)",
+		EnvVar: "SNAPSHOT_FILE",
+	})
+	adminAPIKey := app.String(cli.StringOpt{
+		Name:   "admin-api-key",
+		Desc:   "key expected in the X-Api-Key header of admin requests; admin endpoints are disabled when empty",
+		EnvVar: "ADMIN_API_KEY",
+	})
+	maxDatasetAge := app.String(cli.StringOpt{
+		Name:   "max-dataset-age",
+		Value:  "336h",
+		Desc:   "age of the loaded weekly folder above which the freshness healthcheck fails (0 disables the age check)",
+		EnvVar: "MAX_DATASET_AGE",
+	})
+	kafkaBrokers := app.String(cli.StringOpt{
+		Name:   "kafka-brokers",
+		Desc:   "comma separated kafka brokers to publish the transformed financial instruments to (publishing is disabled when empty)",
+		EnvVar: "KAFKA_BROKERS",
+	})
+	kafkaTopic := app.String(cli.StringOpt{
+		Name:   "kafka-topic",
+		Value:  "FinancialInstruments",
+		Desc:   "kafka topic to publish the transformed financial instruments to",
+		EnvVar: "KAFKA_TOPIC",
+	})
+	selectionRulesFile := app.String(cli.StringOpt{
+		Name:   "selection-rules-file",
+		Desc:   "JSON file with the rules selecting the factset securities and issuers to transform (the active primary shares of public entities when empty)",
+		EnvVar: "SELECTION_RULES_FILE",
+	})
 	port := app.Int(cli.IntOpt{
 		Name:   "port",
 		Value:  8080,
@@ -61,25 +131,88 @@ func main() {
 		}
 		infoLogger.Printf("Config: [bucket: %s] [domain: %s]", s3.bucket, s3.domain)
 
-		s3Loader, err := news3Loader(s3)
-		if err != nil {
-			errorLogger.Printf("[%v]", err)
+		interval := mustParseDuration("refresh-interval", *refreshInterval)
+		maxAge := mustParseDuration("max-dataset-age", *maxDatasetAge)
+		backoff := backoffConfig{
+			initialInterval: mustParseDuration("init-retry-interval", *initRetryInterval),
+			maxInterval:     mustParseDuration("init-retry-max-interval", *initRetryMaxInterval),
+			deadline:        mustParseDuration("init-deadline", *initDeadline),
+		}
+		if *resourcesFolder != "" && !isValidResourcesFolder(*resourcesFolder) {
+			errorLogger.Printf("Invalid resources folder [%s]: [%v]", *resourcesFolder, errInvalidResourcesFolder)
+			cli.Exit(1)
+		}
+
+		var l loader
+		if *localDataDir != "" {
+			infoLogger.Printf("Config: [local data dir: %s]", *localDataDir)
+			fsLoader := newFSLoader(*localDataDir)
+			l = &fsLoader
+		} else {
+			s3Loader, err := news3Loader(s3)
+			if err != nil {
+				errorLogger.Printf("[%v]", err)
+			}
+			l = &s3Loader
+		}
+
+		rules := defaultSelectionRules
+		if *selectionRulesFile != "" {
+			var err error
+			rules, err = loadSelectionRules(*selectionRulesFile)
+			if err != nil {
+				errorLogger.Printf("[%v]", err)
+				cli.Exit(1)
+			}
 		}
+		infoLogger.Printf("Config: [selection rules: %+v]", rules)
 
-		fiParser := fiParserImpl{}
 		fit := fiTransformerImpl{
-			loader: &s3Loader,
-			parser: &fiParser,
+			loader: l,
+			parser: newFIParser(rules),
 		}
 		fis := fiServiceImpl{
-			fit:    &fit,
-			config: s3,
+			fit:          &fit,
+			config:       s3,
+			backoff:      backoff,
+			pinnedFolder: *resourcesFolder,
+		}
+		if *snapshotFile != "" {
+			fis.snapshots = newSnapshotStore(*snapshotFile)
+		}
+		if *kafkaBrokers != "" {
+			infoLogger.Printf("Config: [kafka brokers: %s] [kafka topic: %s]", *kafkaBrokers, *kafkaTopic)
+			producer, err := newKafkaProducer(strings.Split(*kafkaBrokers, ","), *kafkaTopic)
+			if err != nil {
+				errorLogger.Printf("[%v]", err)
+				cli.Exit(1)
+			}
+			fis.publisher = newPublisher(producer)
 		}
 		go func() {
-			fis.Init()
+			if fis.restoreSnapshot() {
+				if err := fis.Refresh(); err != nil {
+					errorLogger.Printf("Could not refresh FIs after restoring snapshot: [%v]", err)
+				}
+			} else {
+				fis.Init()
+			}
+			if interval > 0 {
+				fis.refreshPeriodically(interval, nil)
+			}
 		}()
 
-		httpHandler := &httpHandler{fiService: &fis, baseUrl: *baseUrl}
+		if *adminAPIKey == "" {
+			warnLogger.Println("No admin API key configured, admin endpoints are disabled")
+		}
+		prometheus.MustRegister(newDatasetAgeGauge(&fis))
+		httpHandler := &httpHandler{
+			fiService:     &fis,
+			baseUrl:       *baseUrl,
+			reloader:      newReloader(&fis),
+			adminAPIKey:   *adminAPIKey,
+			maxDatasetAge: maxAge,
+		}
 		listen(httpHandler, *port)
 	}
 
@@ -89,15 +222,43 @@ func main() {
 	}
 }
 
+func mustParseDuration(name string, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		errorLogger.Printf("Invalid %s [%s]: [%v]", name, value, err)
+		cli.Exit(1)
+	}
+	return d
+}
+
 func listen(h *httpHandler, port int) {
 	infoLogger.Println("Listening on port:", port)
 	r := mux.NewRouter()
-	r.HandleFunc("/transformers/financial-instruments/__count", h.Count).Methods("GET")
-	r.HandleFunc("/transformers/financial-instruments/__ids", h.IDs).Methods("GET")
-	r.HandleFunc("/transformers/financial-instruments", h.getFinancialInstruments).Methods("GET")
-	r.HandleFunc("/transformers/financial-instruments/{id}", h.Read).Methods("GET")
-	r.HandleFunc("/__health", v1a.Handler("Financial Instruments Transformer Healthchecks", "Checks for accessing Amazon S3 bucket", h.amazonS3Healthcheck()))
-	r.HandleFunc("/__gtg", h.goodToGo)
+	route := func(path string, f http.HandlerFunc) *mux.Route {
+		return r.HandleFunc(path, withMetrics(path, f))
+	}
+	route("/transformers/financial-instruments/__count", h.Count).Methods("GET")
+	route("/transformers/financial-instruments/__ids", h.IDs).Methods("GET")
+	route("/transformers/financial-instruments/__status", h.Status).Methods("GET")
+	route("/transformers/financial-instruments/__folders", h.Folders).Methods("GET")
+	route("/transformers/financial-instruments/__figi-conflicts", h.FIGIConflicts).Methods("GET")
+	route("/transformers/financial-instruments/__transform-report", h.TransformReport).Methods("GET")
+	route("/transformers/financial-instruments/__changes", h.Changes).Methods("GET")
+	route("/transformers/financial-instruments/__export", h.Export).Methods("GET")
+	route("/transformers/financial-instruments/__search", h.Search).Methods("GET")
+	route("/transformers/financial-instruments/__folders/pinned/{folder}", h.withAdminAuth(h.PinFolder)).Methods("PUT")
+	route("/transformers/financial-instruments/__folders/pinned", h.withAdminAuth(h.UnpinFolder)).Methods("DELETE")
+	route("/transformers/financial-instruments/__reload", h.withAdminAuth(h.Reload)).Methods("POST")
+	route("/transformers/financial-instruments/__reload/{jobID}", h.withAdminAuth(h.ReloadJob)).Methods("GET")
+	route("/transformers/financial-instruments", h.getFinancialInstruments).Methods("GET")
+	route("/transformers/financial-instruments/__bulk", h.BulkRead).Methods("POST")
+	route("/transformers/financial-instruments/{id}", h.Read).Methods("GET")
+	route("/transformers/financial-instruments/figi/{figi}", h.ReadByFIGI).Methods("GET")
+	route("/transformers/financial-instruments/factset/{securityID}", h.ReadByFactsetID).Methods("GET")
+	route("/transformers/financial-instruments/issuer/{orgUUID}", h.ReadByIssuer).Methods("GET")
+	route("/__health", v1a.Handler("Financial Instruments Transformer Healthchecks", "Checks for accessing Amazon S3 bucket and for the freshness of the loaded dataset", h.amazonS3Healthcheck(), h.datasetFreshnessHealthcheck()))
+	route("/__gtg", h.goodToGo)
+	r.Handle("/metrics", promhttp.Handler())
 	err := http.ListenAndServe(":"+strconv.Itoa(port), r)
 	if err != nil {
 		errorLogger.Println(err)
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxBulkIDs caps the identifiers of a bulk read, so that a single request cannot hold a connection for too long
+	maxBulkIDs = 10000
+	// maxBulkBodyBytes leaves room for maxBulkIDs identifiers of any kind
+	maxBulkBodyBytes = 1 << 20
+)
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// limitedBody reads a request body up to a number of bytes, and tells whether the body was longer.
+type limitedBody struct {
+	r        io.Reader
+	left     int64
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > b.left+1 {
+		p = p[:b.left+1]
+	}
+	n, err := b.r.Read(p)
+	if int64(n) > b.left {
+		b.exceeded = true
+		return int(b.left), errBodyTooLarge
+	}
+	b.left -= int64(n)
+	return n, err
+}
+
+// bulkNotFound is the last line of a bulk read response.
+type bulkNotFound struct {
+	NotFound []string `json:"notFound"`
+}
+
+// bulkLookups map the identifier query parameter of a bulk read to the way the identifiers are looked up.
+var bulkLookups = map[string]func(ds *dataset, id string) (string, financialInstrument, bool){
+	"uuid": func(ds *dataset, id string) (string, financialInstrument, bool) {
+		fi, present := ds.Read(id)
+		return id, fi, present
+	},
+	"figi":    (*dataset).ReadByFIGI,
+	"factset": (*dataset).ReadByFactsetID,
+}
+
+// BulkRead reads the financial instruments of a JSON array of identifiers, UUIDs unless the identifier query parameter
+// says "figi" or "factset". The financial instruments found are streamed as newline-delimited JSON in the order
+// of the request, each only once, followed by a last line listing the identifiers that were not found.
+func (h *httpHandler) BulkRead(w http.ResponseWriter, r *http.Request) {
+	ds := h.dataset(w)
+	if ds == nil {
+		return
+	}
+
+	identifier := r.URL.Query().Get("identifier")
+	if identifier == "" {
+		identifier = "uuid"
+	}
+	lookup, ok := bulkLookups[identifier]
+	if !ok {
+		writeMessage(w, http.StatusBadRequest, "identifier must be one of uuid, figi or factset")
+		return
+	}
+
+	var ids []string
+	body := &limitedBody{r: r.Body, left: maxBulkBodyBytes}
+	if err := json.NewDecoder(body).Decode(&ids); err != nil {
+		if body.exceeded {
+			writeMessage(w, http.StatusRequestEntityTooLarge, "A bulk read body is limited to "+strconv.Itoa(maxBulkBodyBytes)+" bytes")
+			return
+		}
+		writeMessage(w, http.StatusBadRequest, "Body must be a JSON array of identifiers")
+		return
+	}
+	if len(ids) > maxBulkIDs {
+		writeMessage(w, http.StatusRequestEntityTooLarge, "A bulk read is limited to "+strconv.Itoa(maxBulkIDs)+" identifiers")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	notFound := bulkNotFound{NotFound: []string{}}
+	requested := make(map[string]bool, len(ids))
+	written := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if requested[id] {
+			continue
+		}
+		requested[id] = true
+		UUID, fi, present := lookup(ds, id)
+		if !present {
+			notFound.NotFound = append(notFound.NotFound, id)
+			continue
+		}
+		// several FIGIs, e.g. of different listings, can lead to the same financial instrument
+		if written[UUID] {
+			continue
+		}
+		written[UUID] = true
+		if err := enc.Encode(toUppFI(UUID, fi)); err != nil {
+			warnLogger.Printf("Could not encode fi with uuid [%s]. Err: [%v]", UUID, err)
+		}
+	}
+	if err := enc.Encode(notFound); err != nil {
+		warnLogger.Printf("Could not encode the identifiers not found. Err: [%v]", err)
+	}
+	if err := bw.Flush(); err != nil {
+		warnLogger.Printf("Could not write bulk read response: [%v]", err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkRead(t *testing.T) {
+	m := map[string]financialInstrument{
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": {
+			figiCode:     "BBG000JPVHS1",
+			securityID:   "JBP7Z8-S",
+			orgID:        "ea90a425-73be-33c5-9aa4-939c9a46b87a",
+			securityName: "Industrija Precizne Mehanike AD",
+		},
+		"8404dbec-2423-322d-9afa-f92e553e53b6": {
+			figiCode:     "BBG000BDN0W4",
+			securityID:   "GG9B0P-S",
+			orgID:        "21fbf032-23e7-34d3-970c-45432b455fd9",
+			securityName: "Marks & Spencer Group Plc",
+			listings:     []listing{{listingID: "MLKNP9-L", figiCode: "BBG000BDN0W4", primary: true}, {listingID: "T9X6PL-L", figiCode: "BBG000BDN1K7"}},
+		},
+	}
+	ipm := `{"uuid":"404c8329-3f8e-348e-ba32-cf3eb2c1ffed","prefLabel":"Industrija Precizne Mehanike AD","alternativeIdentifiers":{"uuids":["404c8329-3f8e-348e-ba32-cf3eb2c1ffed"],"factsetIdentifier":"JBP7Z8-S","figiCode":"BBG000JPVHS1"},"issuedBy":"ea90a425-73be-33c5-9aa4-939c9a46b87a"}` + "\n"
+	mks := `{"uuid":"8404dbec-2423-322d-9afa-f92e553e53b6","prefLabel":"Marks \u0026 Spencer Group Plc","alternativeIdentifiers":{"uuids":["8404dbec-2423-322d-9afa-f92e553e53b6"],"factsetIdentifier":"GG9B0P-S","figiCode":"BBG000BDN0W4"},"issuedBy":"21fbf032-23e7-34d3-970c-45432b455fd9","listings":[{"factsetIdentifier":"MLKNP9-L","figiCode":"BBG000BDN0W4","primary":true},{"factsetIdentifier":"T9X6PL-L","figiCode":"BBG000BDN1K7","primary":false}]}` + "\n"
+
+	var testCases = []struct {
+		name         string
+		query        string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "uuids in request order, not found reported last",
+			body:         `["8404dbec-2423-322d-9afa-f92e553e53b6","24d7f133-d30b-394f-970c-5a5e3ed66061","404c8329-3f8e-348e-ba32-cf3eb2c1ffed"]`,
+			expectedCode: http.StatusOK,
+			expectedBody: mks + ipm + `{"notFound":["24d7f133-d30b-394f-970c-5a5e3ed66061"]}` + "\n",
+		},
+		{
+			name:         "figis of several listings of one financial instrument",
+			query:        "?identifier=figi",
+			body:         `["BBG000BDN1K7","BBG000BDN0W4","BBG000BDN0W4"]`,
+			expectedCode: http.StatusOK,
+			expectedBody: mks + `{"notFound":[]}` + "\n",
+		},
+		{
+			name:         "factset ids",
+			query:        "?identifier=factset",
+			body:         `["JBP7Z8-S","K7TPSX-S"]`,
+			expectedCode: http.StatusOK,
+			expectedBody: ipm + `{"notFound":["K7TPSX-S"]}` + "\n",
+		},
+		{
+			name:         "empty array",
+			body:         `[]`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"notFound":[]}` + "\n",
+		},
+		{
+			name:         "unknown identifier",
+			query:        "?identifier=isin",
+			body:         `["GB0031274896"]`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"message":"identifier must be one of uuid, figi or factset"}` + "\n",
+		},
+		{
+			name:         "not an array",
+			body:         `{"uuids":["404c8329-3f8e-348e-ba32-cf3eb2c1ffed"]}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"message":"Body must be a JSON array of identifiers"}` + "\n",
+		},
+		{
+			name:         "too many identifiers",
+			body:         `[` + strings.Repeat(`"a",`, maxBulkIDs) + `"a"]`,
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedBody: `{"message":"A bulk read is limited to 10000 identifiers"}` + "\n",
+		},
+		{
+			name:         "body too large",
+			body:         `["` + strings.Repeat("a", maxBulkBodyBytes) + `"]`,
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedBody: `{"message":"A bulk read body is limited to 1048576 bytes"}` + "\n",
+		},
+	}
+
+	h := httpHandler{fiService: serving(&fiServiceImpl{}, "2017-08-10", m)}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.BulkRead(w, httptest.NewRequest("POST", "/transformers/financial-instruments/__bulk"+tc.query, strings.NewReader(tc.body)))
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+			if tc.expectedCode == http.StatusOK {
+				assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestBulkRead_NotInitialisedService_StatusServiceUnavailable(t *testing.T) {
+	h := httpHandler{fiService: &fiServiceImpl{}}
+	w := httptest.NewRecorder()
+
+	h.BulkRead(w, httptest.NewRequest("POST", "/transformers/financial-instruments/__bulk", strings.NewReader(`[]`)))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// cache policies of the endpoints served from the dataset. Financial instruments only change with a reload,
+// while the listings may shift with every one of them and the reports are only looked at to diagnose the last reload.
+const (
+	cacheRecords  = "public, max-age=3600"
+	cacheListings = "public, max-age=300"
+	cacheReports  = "no-cache"
+)
+
+// entityTag derives the ETag of a response from the version of the dataset it is read from and from its content,
+// which is the body for the records and the request for the streamed listings.
+func entityTag(version string, content []byte) string {
+	h := sha1.New()
+	h.Write([]byte(version))
+	h.Write([]byte{0})
+	h.Write(content)
+	return `"` + hex.EncodeToString(h.Sum(nil))[:16] + `"`
+}
+
+// cached sets the validators and the cache policy of a response served from the dataset.
+// When the conditions of the request show the client already holds the response, it answers with a 304 and returns true.
+// Last-Modified is the time the dataset was loaded.
+func cached(w http.ResponseWriter, r *http.Request, ds *dataset, etag string, cacheControl string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", ds.loaded.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", cacheControl)
+	if !notModified(r, etag, ds.loaded) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when the former is absent.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(ims)
+}
+
+// etagMatches tells whether the If-None-Match header names the ETag. The comparison is weak, as GET requires.
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCached writes a body read from the dataset with its validators and cache policy, unless the client already holds it.
+func writeCached(w http.ResponseWriter, r *http.Request, ds *dataset, body []byte, cacheControl string) {
+	if cached(w, r, ds, entityTag(ds.version, body), cacheControl) {
+		return
+	}
+	if _, err := w.Write(body); err != nil {
+		warnLogger.Printf("Could not write [%s] response: [%v]", r.URL.Path, err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotModified(t *testing.T) {
+	loaded := time.Date(2017, time.August, 10, 9, 30, 15, 500, time.UTC)
+	etag := `"0123456789abcdef"`
+
+	var testCases = []struct {
+		name     string
+		headers  map[string]string
+		expected bool
+	}{
+		{name: "no conditions", expected: false},
+		{name: "matching etag", headers: map[string]string{"If-None-Match": etag}, expected: true},
+		{name: "matching weak etag among others", headers: map[string]string{"If-None-Match": `"fedcba9876543210", W/"0123456789abcdef"`}, expected: true},
+		{name: "any etag", headers: map[string]string{"If-None-Match": "*"}, expected: true},
+		{name: "other etag", headers: map[string]string{"If-None-Match": `"fedcba9876543210"`}, expected: false},
+		{name: "modified since", headers: map[string]string{"If-Modified-Since": "Thu, 10 Aug 2017 09:30:14 GMT"}, expected: false},
+		{name: "not modified since", headers: map[string]string{"If-Modified-Since": "Thu, 10 Aug 2017 09:30:15 GMT"}, expected: true},
+		{name: "invalid date", headers: map[string]string{"If-Modified-Since": "yesterday"}, expected: false},
+		{
+			name:     "etag takes precedence over date",
+			headers:  map[string]string{"If-None-Match": `"fedcba9876543210"`, "If-Modified-Since": "Thu, 10 Aug 2017 09:30:15 GMT"},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/transformers/financial-instruments/foo", nil)
+			for name, value := range tc.headers {
+				r.Header.Set(name, value)
+			}
+			assert.Equal(t, tc.expected, notModified(r, etag, loaded))
+		})
+	}
+}
+
+func TestRead_ConditionalGet(t *testing.T) {
+	fi := financialInstrument{figiCode: "BBG000JPVHS1", securityID: "JBP7Z8-S", securityName: "Industrija Precizne Mehanike AD"}
+	s := &fiServiceImpl{}
+	loaded := time.Date(2017, time.August, 10, 9, 30, 0, 0, time.UTC)
+	s.swap(newDataset("2017-08-10", loaded, transformResult{financialInstruments: map[string]financialInstrument{"foo": fi}}, datasetChanges{}))
+	h := httpHandler{fiService: s}
+	r := mux.NewRouter()
+	r.HandleFunc("/{id}", h.Read)
+
+	get := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/foo", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "Thu, 10 Aug 2017 09:30:00 GMT", w.Header().Get("Last-Modified"))
+	assert.Equal(t, cacheRecords, w.Header().Get("Cache-Control"))
+
+	w = get(map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	w = get(map[string]string{"If-Modified-Since": "Thu, 10 Aug 2017 10:00:00 GMT"})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	fi.securityName = "Industrija Precizne Mehanike AD Beograd"
+	s.swap(newDataset("2017-08-10", loaded.Add(time.Hour), transformResult{financialInstruments: map[string]financialInstrument{"foo": fi}}, datasetChanges{}))
+
+	w = get(map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), "Industrija Precizne Mehanike AD Beograd")
+}
+
+func TestIDs_ConditionalGet(t *testing.T) {
+	s := serving(&fiServiceImpl{}, "2017-08-10", map[string]financialInstrument{"a": {}, "b": {}})
+	h := httpHandler{fiService: s}
+
+	w := httptest.NewRecorder()
+	h.IDs(w, httptest.NewRequest("GET", "/transformers/financial-instruments/__ids", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, cacheListings, w.Header().Get("Cache-Control"))
+	etag := w.Header().Get("ETag")
+
+	req := httptest.NewRequest("GET", "/transformers/financial-instruments/__ids", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.IDs(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	req = httptest.NewRequest("GET", "/transformers/financial-instruments/__ids?limit=1", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.IDs(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "another page has another ETag")
+}
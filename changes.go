@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// datasetChanges lists the financial instruments added, removed and modified by the last reload.
+type datasetChanges struct {
+	FromFolder string     `json:"fromFolder"`
+	ToFolder   string     `json:"toFolder"`
+	Computed   time.Time  `json:"computed"`
+	Added      []string   `json:"added"`
+	Removed    []string   `json:"removed"`
+	Modified   []fiChange `json:"modified"`
+}
+
+type fiChange struct {
+	UUID   string        `json:"uuid"`
+	Fields []fieldChange `json:"fields"`
+}
+
+type fieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// diffFIs compares two datasets. The UUIDs are derived from the Factset security IDs, so a security keeps its UUID
+// from one week to the next and only its other attributes can change. All the lists are sorted by UUID.
+func diffFIs(old map[string]financialInstrument, new map[string]financialInstrument) datasetChanges {
+	changes := datasetChanges{
+		Added:    []string{},
+		Removed:  []string{},
+		Modified: []fiChange{},
+	}
+	for UUID, newFI := range new {
+		oldFI, present := old[UUID]
+		if !present {
+			changes.Added = append(changes.Added, UUID)
+			continue
+		}
+		if fields := diffFI(oldFI, newFI); len(fields) > 0 {
+			changes.Modified = append(changes.Modified, fiChange{UUID: UUID, Fields: fields})
+		}
+	}
+	for UUID := range old {
+		if _, present := new[UUID]; !present {
+			changes.Removed = append(changes.Removed, UUID)
+		}
+	}
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Slice(changes.Modified, func(i, j int) bool {
+		return changes.Modified[i].UUID < changes.Modified[j].UUID
+	})
+	return changes
+}
+
+// diffFI returns the changed fields, named as in the uppFI representation.
+func diffFI(old financialInstrument, new financialInstrument) []fieldChange {
+	var fields []fieldChange
+	if old.securityName != new.securityName {
+		fields = append(fields, fieldChange{Field: "prefLabel", Old: old.securityName, New: new.securityName})
+	}
+	if old.figiCode != new.figiCode {
+		fields = append(fields, fieldChange{Field: "figiCode", Old: old.figiCode, New: new.figiCode})
+	}
+	if old.orgID != new.orgID {
+		fields = append(fields, fieldChange{Field: "issuedBy", Old: old.orgID, New: new.orgID})
+	}
+	if old.ticker != new.ticker {
+		fields = append(fields, fieldChange{Field: "ticker", Old: old.ticker, New: new.ticker})
+	}
+	if old.exchange != new.exchange {
+		fields = append(fields, fieldChange{Field: "exchange", Old: old.exchange, New: new.exchange})
+	}
+	if old.currency != new.currency {
+		fields = append(fields, fieldChange{Field: "currency", Old: old.currency, New: new.currency})
+	}
+	if old.issuerCountry != new.issuerCountry {
+		fields = append(fields, fieldChange{Field: "issuerCountry", Old: old.issuerCountry, New: new.issuerCountry})
+	}
+	if !reflect.DeepEqual(old.listings, new.listings) {
+		fields = append(fields, fieldChange{Field: "listings", Old: listingsJSON(old.listings), New: listingsJSON(new.listings)})
+	}
+	return fields
+}
+
+// listingsJSON renders the listings as in the uppFI representation.
+func listingsJSON(listings []listing) string {
+	b, err := json.Marshal(toUppListings(listings))
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// changed returns the added and modified financial instruments of the new dataset.
+func (c datasetChanges) changed(fis map[string]financialInstrument) map[string]financialInstrument {
+	changed := make(map[string]financialInstrument, len(c.Added)+len(c.Modified))
+	for UUID := range c.changedIDs() {
+		changed[UUID] = fis[UUID]
+	}
+	return changed
+}
+
+// changedIDs returns the UUIDs of the added and modified financial instruments.
+func (c datasetChanges) changedIDs() map[string]bool {
+	UUIDs := make(map[string]bool, len(c.Added)+len(c.Modified))
+	for _, UUID := range c.Added {
+		UUIDs[UUID] = true
+	}
+	for _, m := range c.Modified {
+		UUIDs[m.UUID] = true
+	}
+	return UUIDs
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffFIs(t *testing.T) {
+	ipm := financialInstrument{
+		figiCode:     "BBG000JPVHS1",
+		securityID:   "JBP7Z8-S",
+		orgID:        "ea90a425-73be-33c5-9aa4-939c9a46b87a",
+		securityName: "Industrija Precizne Mehanike AD",
+	}
+	mks := financialInstrument{
+		figiCode:     "BBG000BDN0W4",
+		securityID:   "GG9B0P-S",
+		orgID:        "21fbf032-23e7-34d3-970c-45432b455fd9",
+		securityName: "Marks and Spencer Group Plc",
+	}
+	renamed := mks
+	renamed.securityName = "Marks & Spencer Group Plc"
+	renamed.figiCode = "BBG000BDN0W5"
+	renamed.ticker = "MKS LN"
+	renamed.listings = []listing{{listingID: "MLKNP9-L", figiCode: "BBG000BDN0W5", ticker: "MKS LN", primary: true}}
+	rmc := financialInstrument{
+		figiCode:     "BBG000CXWV71",
+		securityID:   "K7TPSX-S",
+		orgID:        "b1b6a8a3-2f65-3b9e-8a0c-4c5ef2c7a9a1",
+		securityName: "Ralph Martindale and Company Ltd",
+	}
+
+	old := map[string]financialInstrument{
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": ipm,
+		"8404dbec-2423-322d-9afa-f92e553e53b6": mks,
+		"1b1f1e6e-6a83-3f0c-9d6f-0b6b4bd9f2a7": rmc,
+	}
+	new := map[string]financialInstrument{
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": ipm,
+		"8404dbec-2423-322d-9afa-f92e553e53b6": renamed,
+		"f1c1d3a5-3e1f-3b0a-a0e4-2d1ae2b1c9de": rmc,
+	}
+
+	changes := diffFIs(old, new)
+
+	assert.Equal(t, []string{"f1c1d3a5-3e1f-3b0a-a0e4-2d1ae2b1c9de"}, changes.Added)
+	assert.Equal(t, []string{"1b1f1e6e-6a83-3f0c-9d6f-0b6b4bd9f2a7"}, changes.Removed)
+	assert.Equal(t, []fiChange{
+		{
+			UUID: "8404dbec-2423-322d-9afa-f92e553e53b6",
+			Fields: []fieldChange{
+				{Field: "prefLabel", Old: "Marks and Spencer Group Plc", New: "Marks & Spencer Group Plc"},
+				{Field: "figiCode", Old: "BBG000BDN0W4", New: "BBG000BDN0W5"},
+				{Field: "ticker", Old: "", New: "MKS LN"},
+				{Field: "listings", Old: "null", New: `[{"factsetIdentifier":"MLKNP9-L","figiCode":"BBG000BDN0W5","ticker":"MKS LN","primary":true}]`},
+			},
+		},
+	}, changes.Modified)
+
+	changed := changes.changed(new)
+	assert.Equal(t, map[string]financialInstrument{
+		"8404dbec-2423-322d-9afa-f92e553e53b6": renamed,
+		"f1c1d3a5-3e1f-3b0a-a0e4-2d1ae2b1c9de": rmc,
+	}, changed)
+}
+
+func TestDiffFIs_FirstLoad_EverythingIsAdded(t *testing.T) {
+	new := map[string]financialInstrument{
+		"8404dbec-2423-322d-9afa-f92e553e53b6": {securityID: "GG9B0P-S"},
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": {securityID: "JBP7Z8-S"},
+	}
+
+	changes := diffFIs(nil, new)
+
+	assert.Equal(t, []string{"404c8329-3f8e-348e-ba32-cf3eb2c1ffed", "8404dbec-2423-322d-9afa-f92e553e53b6"}, changes.Added)
+	assert.Empty(t, changes.Removed)
+	assert.Empty(t, changes.Modified)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// column names of the Factset files, as found in their header rows
+const (
+	colFSYMID           = "FSYM_ID"
+	colCurrency         = "CURRENCY"
+	colProperName       = "PROPER_NAME"
+	colPrimaryEquityID  = "FSYM_PRIMARY_EQUITY_ID"
+	colPrimaryListingID = "FSYM_PRIMARY_LISTING_ID"
+	colActiveFlag       = "ACTIVE_FLAG"
+	colSecurityType     = "FREF_SECURITY_TYPE"
+	colListingExchange  = "FREF_LISTING_EXCHANGE"
+	colRegionalID       = "FSYM_REGIONAL_ID"
+	colSecurityID       = "FSYM_SECURITY_ID"
+	colUniverseType     = "UNIVERSE_TYPE"
+	colFactsetEntityID  = "FACTSET_ENTITY_ID"
+	colBloombergID      = "BBG_ID"
+	colBloombergTicker  = "BBG_TICKER"
+	colEntityISOCountry = "ISO_COUNTRY"
+	colEntityType       = "ENTITY_TYPE"
+)
+
+var errMissingColumn = errors.New("missing column")
+
+// columns maps the names of the required columns of a file to their positions in its records,
+// so that records are read by column name whatever the order of the columns and however many others there are.
+type columns struct {
+	indexes map[string]int
+	width   int
+}
+
+// readColumns reads the header row of the file and checks it holds every required column.
+// An empty file has no header row and no records, and is not an error.
+func readColumns(rows *pipeReader, required ...string) (columns, error) {
+	cols := columns{indexes: make(map[string]int, len(required))}
+	if !rows.next() {
+		return cols, rows.err()
+	}
+	if line, malformed := rows.malformed(); malformed {
+		return columns{}, errors.Errorf("file [%s] has a malformed header row at line [%d]", rows.file, line)
+	}
+	positions := make(map[string]int)
+	for i, name := range rows.fields() {
+		positions[strings.TrimSpace(name)] = i
+	}
+	for _, name := range required {
+		i, present := positions[name]
+		if !present {
+			return columns{}, errors.Wrapf(errMissingColumn, "file [%s] has no column [%s]", rows.file, name)
+		}
+		cols.indexes[name] = i
+		if i+1 > cols.width {
+			cols.width = i + 1
+		}
+	}
+	return cols, nil
+}
+
+// complete tells whether the record holds all the required columns.
+func (c columns) complete(record []string) bool {
+	return len(record) >= c.width
+}
+
+// get returns the value of the named column of a complete record.
+func (c columns) get(record []string, name string) string {
+	return record[c.indexes[name]]
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+const datasetVersionHeader = "X-Dataset-Version"
+
+// dataset is one loaded version of the financial instruments, together with everything derived from them.
+// A dataset is never modified once built: a reload builds a new one and swaps it in whole,
+// so that a request holding a dataset is served from a single consistent version whatever reloads happen meanwhile.
+// A nil dataset stands for a service that has not loaded any yet.
+type dataset struct {
+	version              string
+	resourcesFolder      string
+	dailyFolders         []string
+	loaded               time.Time
+	financialInstruments map[string]financialInstrument
+	figiConflicts        []figiConflict
+	report               transformReport
+	changes              datasetChanges
+	indexes              fiIndexes
+	search               searchIndex
+}
+
+func newDataset(folder string, loaded time.Time, result transformResult, changes datasetChanges) *dataset {
+	indexes := newFIIndexes(result.financialInstruments)
+	return &dataset{
+		version:              datasetVersion(result.financialInstruments, indexes.sortedIDs),
+		resourcesFolder:      folder,
+		dailyFolders:         result.report.DailyFolders,
+		loaded:               loaded,
+		financialInstruments: result.financialInstruments,
+		figiConflicts:        result.figiConflicts,
+		report:               result.report,
+		changes:              changes,
+		indexes:              indexes,
+		search:               newSearchIndex(result.financialInstruments),
+	}
+}
+
+// datasetVersion hashes the financial instruments in UUID order, so that the same financial instruments
+// get the same version whichever instance loaded them and whenever they were loaded.
+func datasetVersion(fis map[string]financialInstrument, sortedIDs []string) string {
+	h := sha1.New()
+	for _, UUID := range sortedIDs {
+		fmt.Fprintf(h, "%s|%+v\n", UUID, fis[UUID])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func (ds *dataset) Version() string {
+	if ds == nil {
+		return ""
+	}
+	return ds.version
+}
+
+func (ds *dataset) Read(UUID string) (financialInstrument, bool) {
+	if ds == nil {
+		return financialInstrument{}, false
+	}
+	fi, present := ds.financialInstruments[UUID]
+	return fi, present
+}
+
+func (ds *dataset) ReadByFIGI(figi string) (string, financialInstrument, bool) {
+	if ds == nil {
+		return "", financialInstrument{}, false
+	}
+	return ds.readIndexed(ds.indexes.byFIGI, figi)
+}
+
+func (ds *dataset) ReadByFactsetID(securityID string) (string, financialInstrument, bool) {
+	if ds == nil {
+		return "", financialInstrument{}, false
+	}
+	return ds.readIndexed(ds.indexes.byFactsetID, securityID)
+}
+
+func (ds *dataset) readIndexed(index map[string]string, key string) (string, financialInstrument, bool) {
+	UUID, present := index[key]
+	if !present {
+		return "", financialInstrument{}, false
+	}
+	fi, present := ds.financialInstruments[UUID]
+	return UUID, fi, present
+}
+
+// IssuedBy returns the sorted UUIDs of the financial instruments issued by the given organisation.
+func (ds *dataset) IssuedBy(orgUUID string) []string {
+	if ds == nil {
+		return nil
+	}
+	return ds.indexes.byIssuer[orgUUID]
+}
+
+// FIGIConflicts returns the conflicts found while transforming the dataset.
+func (ds *dataset) FIGIConflicts() []figiConflict {
+	if ds == nil || ds.figiConflicts == nil {
+		return []figiConflict{}
+	}
+	return ds.figiConflicts
+}
+
+// TransformReport returns the report of the transformation that produced the dataset.
+func (ds *dataset) TransformReport() transformReport {
+	if ds == nil {
+		return transformReport{}
+	}
+	return ds.report
+}
+
+// Changes returns the differences between the dataset and the one it replaced.
+func (ds *dataset) Changes() datasetChanges {
+	if ds == nil || ds.changes.Added == nil {
+		// no reload since start-up, e.g. the dataset was restored from a snapshot
+		return diffFIs(nil, nil)
+	}
+	return ds.changes
+}
+
+// IDs returns the UUIDs of the financial instruments in ascending order.
+// The returned slice is shared between callers and must not be modified.
+func (ds *dataset) IDs() []string {
+	if ds == nil || ds.indexes.sortedIDs == nil {
+		return []string{}
+	}
+	return ds.indexes.sortedIDs
+}
+
+// Search returns the UUIDs of at most limit financial instruments whose name matches the query, best matches first.
+func (ds *dataset) Search(query string, limit int) []string {
+	if ds == nil {
+		return []string{}
+	}
+	return ds.search.search(query, limit)
+}
+
+func (ds *dataset) Count() int {
+	if ds == nil {
+		return 0
+	}
+	return len(ds.financialInstruments)
+}
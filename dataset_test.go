@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDataset_VersionDependsOnTheFinancialInstrumentsOnly(t *testing.T) {
+	fis := map[string]financialInstrument{
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": {securityID: "JBP7Z8-S", securityName: "Industrija Precizne Mehanike AD"},
+		"8404dbec-2423-322d-9afa-f92e553e53b6": {securityID: "GG9B0P-S", securityName: "Marks & Spencer Group Plc"},
+	}
+	copied := map[string]financialInstrument{}
+	for UUID, fi := range fis {
+		copied[UUID] = fi
+	}
+	renamed := map[string]financialInstrument{}
+	for UUID, fi := range fis {
+		renamed[UUID] = fi
+	}
+	renamed["8404dbec-2423-322d-9afa-f92e553e53b6"] = financialInstrument{securityID: "GG9B0P-S", securityName: "Marks and Spencer Group Plc"}
+
+	ds := newDataset("2017-08-10", time.Now(), transformResult{financialInstruments: fis}, datasetChanges{})
+	reloaded := newDataset("2017-08-17", time.Now().Add(time.Hour), transformResult{financialInstruments: copied}, datasetChanges{})
+	modified := newDataset("2017-08-17", time.Now(), transformResult{financialInstruments: renamed}, datasetChanges{})
+
+	assert.Len(t, ds.Version(), 16)
+	assert.Equal(t, ds.Version(), reloaded.Version())
+	assert.NotEqual(t, ds.Version(), modified.Version())
+}
+
+func TestDataset_Nil_NothingIsServed(t *testing.T) {
+	var ds *dataset
+
+	assert.Equal(t, "", ds.Version())
+	assert.Equal(t, 0, ds.Count())
+	assert.Equal(t, []string{}, ds.IDs())
+	assert.Equal(t, []figiConflict{}, ds.FIGIConflicts())
+	assert.Equal(t, diffFIs(nil, nil), ds.Changes())
+	_, present := ds.Read("404c8329-3f8e-348e-ba32-cf3eb2c1ffed")
+	assert.False(t, present)
+	_, _, present = ds.ReadByFIGI("BBG000JPVHS1")
+	assert.False(t, present)
+}
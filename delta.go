@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// deletesSuffix names the file of a daily zip listing the keys of the rows deleted from a file, e.g. sym_bbg_deletes.
+const deletesSuffix = "_deletes"
+
+// deltaBundle applies daily delta archives, in order, on top of a weekly baseline.
+// Every file is keyed by the first column of its weekly version, which the daily files hold under the same name,
+// whatever its position. A daily file holds the rows inserted or updated that day, and the matching _deletes file
+// the keys of the rows deleted that day; deletions are applied after the upserts. A daily zip only contains the files that changed.
+// A file is merged once, when first read, and kept for the following reads.
+type deltaBundle struct {
+	sync.Mutex
+	weekly  resourceBundle
+	dailies []resourceBundle
+	merged  map[string][]byte
+}
+
+func newDeltaBundle(weekly resourceBundle, dailies []resourceBundle) resourceBundle {
+	return &deltaBundle{weekly: weekly, dailies: dailies, merged: make(map[string][]byte)}
+}
+
+func (d *deltaBundle) get(name string) (io.ReadCloser, error) {
+	d.Lock()
+	defer d.Unlock()
+	merged, present := d.merged[name]
+	if !present {
+		var err error
+		if merged, err = d.merge(name); err != nil {
+			return nil, err
+		}
+		d.merged[name] = merged
+	}
+	return ioutil.NopCloser(bytes.NewReader(merged)), nil
+}
+
+// merge returns the weekly file with the daily files applied, in the columns of the weekly file.
+func (d *deltaBundle) merge(name string) ([]byte, error) {
+	r, err := d.weekly.get(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	rows, err := newKeyedRows(newPipeReader(r, name))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read weekly file [%s]", name)
+	}
+
+	for _, daily := range d.dailies {
+		if err := applyDaily(daily, name, rows); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	rows.writeTo(&buf)
+	return buf.Bytes(), nil
+}
+
+// Close closes the weekly and the daily bundles.
+func (d *deltaBundle) Close() error {
+	err := d.weekly.Close()
+	for _, daily := range d.dailies {
+		if dailyErr := daily.Close(); err == nil {
+			err = dailyErr
+		}
+	}
+	return err
+}
+
+func applyDaily(daily resourceBundle, name string, rows *keyedRows) error {
+	upserts, err := daily.get(name)
+	if err == nil {
+		err = rows.upsert(newPipeReader(upserts, name))
+		upserts.Close()
+		if err != nil {
+			return errors.Wrapf(err, "could not read daily file [%s]", name)
+		}
+	} else if errors.Cause(err) != errResourceNotFound {
+		return err
+	}
+
+	deletes, err := daily.get(name + deletesSuffix)
+	if err == nil {
+		err = rows.delete(newPipeReader(deletes, name+deletesSuffix))
+		deletes.Close()
+		if err != nil {
+			return errors.Wrapf(err, "could not read daily file [%s]", name+deletesSuffix)
+		}
+	} else if errors.Cause(err) != errResourceNotFound {
+		return err
+	}
+	return nil
+}
+
+// keyedRows keeps the records of a file by key, in the order the keys were first seen, with the columns of the weekly file.
+type keyedRows struct {
+	header []string
+	keys   []string
+	seen   map[string]bool
+	rows   map[string][]string
+}
+
+// newKeyedRows reads the weekly file, whose first column is the key. Malformed records are logged and left out.
+func newKeyedRows(rows *pipeReader) (*keyedRows, error) {
+	k := &keyedRows{seen: make(map[string]bool), rows: make(map[string][]string)}
+	if !rows.next() {
+		return k, rows.err()
+	}
+	if line, malformed := rows.malformed(); malformed {
+		return nil, errors.Errorf("file [%s] has a malformed header row at line [%d]", rows.file, line)
+	}
+	for _, name := range rows.fields() {
+		k.header = append(k.header, strings.TrimSpace(name))
+	}
+	for rows.next() {
+		if record := rows.fields(); record != nil {
+			k.put(record[0], record)
+		}
+	}
+	return k, rows.err()
+}
+
+func (k *keyedRows) put(key string, record []string) {
+	if key == "" {
+		return
+	}
+	if !k.seen[key] {
+		k.seen[key] = true
+		k.keys = append(k.keys, key)
+	}
+	k.rows[key] = record
+}
+
+// upsert inserts or replaces the records of a daily file, taking its columns by name into the columns of the weekly file.
+func (k *keyedRows) upsert(rows *pipeReader) error {
+	if len(k.header) == 0 {
+		return nil
+	}
+	cols, err := readColumns(rows, k.header...)
+	if err != nil || cols.width == 0 {
+		return err
+	}
+	for rows.next() {
+		record := rows.fields()
+		if record == nil || !cols.complete(record) {
+			continue
+		}
+		row := make([]string, len(k.header))
+		for i, name := range k.header {
+			row[i] = cols.get(record, name)
+		}
+		k.put(row[0], row)
+	}
+	return rows.err()
+}
+
+// delete removes the records whose keys are listed in a _deletes file, under the name of the key column.
+func (k *keyedRows) delete(rows *pipeReader) error {
+	if len(k.header) == 0 {
+		return nil
+	}
+	cols, err := readColumns(rows, k.header[0])
+	if err != nil || cols.width == 0 {
+		return err
+	}
+	for rows.next() {
+		if record := rows.fields(); record != nil && cols.complete(record) {
+			delete(k.rows, cols.get(record, k.header[0]))
+		}
+	}
+	return rows.err()
+}
+
+// writeTo writes the header and the records pipe-delimited, with every field quoted.
+func (k *keyedRows) writeTo(buf *bytes.Buffer) {
+	if len(k.header) == 0 {
+		return
+	}
+	writeQuotedRecord(buf, k.header)
+	for _, key := range k.keys {
+		if record, present := k.rows[key]; present {
+			writeQuotedRecord(buf, record)
+		}
+	}
+}
+
+func writeQuotedRecord(buf *bytes.Buffer, record []string) {
+	for i, field := range record {
+		if i > 0 {
+			buf.WriteByte('|')
+		}
+		buf.WriteByte('"')
+		buf.WriteString(strings.Replace(field, `"`, `""`, -1))
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('\n')
+}
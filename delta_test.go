@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// filesBundle is a resource bundle holding the given files, missing files are reported as not found.
+func filesBundle(files map[string]string) resourceBundle {
+	return &mockResourceBundle{
+		mockGet: func(name string) (io.ReadCloser, error) {
+			body, present := files[name]
+			if !present {
+				return nil, errResourceNotFound
+			}
+			return ioutil.NopCloser(strings.NewReader(body)), nil
+		},
+	}
+}
+
+func TestDeltaBundle_Get(t *testing.T) {
+	header := `"FSYM_ID"|"BBG_ID"|"BBG_TICKER"`
+	weekly := filesBundle(map[string]string{
+		secToFIGIs: header + "\n" +
+			`"MLKNP9-L"|"BBG000BDN0W4"|"MKS LN"` + "\n" +
+			`"M679DF-L"|"BBG000JPVHS1"|"IPMB SG"` + "\n" +
+			`"V0CJ4K-L"|"BBG000CXWV71"|"RMC LN"`,
+	})
+	monday := filesBundle(map[string]string{
+		secToFIGIs: header + "\n" +
+			`"M679DF-L"|"BBG000JPVHS2"|"IPMB SG"` + "\n" +
+			`"Q2RS8T-L"|"BBG000QQQQQ1"|"NEW LN"`,
+		secToFIGIs + deletesSuffix: `"FSYM_ID"` + "\n" +
+			`"V0CJ4K-L"`,
+	})
+	tuesday := filesBundle(map[string]string{
+		secToFIGIs: header + "\n" +
+			`"V0CJ4K-L"|"BBG000CXWV72"|"RMC LN"`,
+		secToFIGIs + deletesSuffix: `"FSYM_ID"` + "\n" +
+			`"MLKNP9-L"`,
+	})
+	// a daily zip only contains the files that changed
+	wednesday := filesBundle(map[string]string{})
+
+	r, err := newDeltaBundle(weekly, []resourceBundle{monday, tuesday, wednesday}).get(secToFIGIs)
+	require.NoError(t, err)
+	defer r.Close()
+	body, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	expected := header + "\n" +
+		`"M679DF-L"|"BBG000JPVHS2"|"IPMB SG"` + "\n" +
+		`"V0CJ4K-L"|"BBG000CXWV72"|"RMC LN"` + "\n" +
+		`"Q2RS8T-L"|"BBG000QQQQQ1"|"NEW LN"` + "\n"
+	assert.Equal(t, expected, string(body))
+}
+
+func TestDeltaBundle_Get_MissingWeeklyFile(t *testing.T) {
+	_, err := newDeltaBundle(filesBundle(map[string]string{}), nil).get(secToFIGIs)
+
+	assert.Error(t, err)
+}
+
+func TestDeltaBundle_Get_DailyColumnsAreMatchedByName(t *testing.T) {
+	weekly := filesBundle(map[string]string{
+		securities: `"FSYM_ID"|"PROPER_NAME"|"ACTIVE_FLAG"` + "\n" +
+			`"JBP7Z8-S"|"Industrija | Precizne ""IPM"" AD"|1` + "\n" +
+			`"GG9B0P-S"|"Marks & Spencer Group Plc"|1` + "\n" +
+			`"K7TPSX-S"|"Ralph Martindale & Company Ltd"|0`,
+	})
+	// reordered and added columns, a multi-line name and a deletes file with the key as its second column
+	monday := filesBundle(map[string]string{
+		securities: `"ACTIVE_FLAG"|"ISO_COUNTRY"|"PROPER_NAME"|"FSYM_ID"` + "\n" +
+			`0|"RS"|"Industrija Precizne Mehanike AD"|"JBP7Z8-S"` + "\n" +
+			`1|"GB"|"Ralph Martindale` + "\n" + `& Company Ltd"|"K7TPSX-S"`,
+		securities + deletesSuffix: `"DELETED"|"FSYM_ID"` + "\n" +
+			`"2017-08-14"|"GG9B0P-S"`,
+	})
+
+	r, err := newDeltaBundle(weekly, []resourceBundle{monday}).get(securities)
+	require.NoError(t, err)
+	defer r.Close()
+	body, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	expected := `"FSYM_ID"|"PROPER_NAME"|"ACTIVE_FLAG"` + "\n" +
+		`"JBP7Z8-S"|"Industrija Precizne Mehanike AD"|"0"` + "\n" +
+		`"K7TPSX-S"|"Ralph Martindale` + "\n" + `& Company Ltd"|"1"` + "\n"
+	assert.Equal(t, expected, string(body))
+}
+
+func TestDeltaBundle_Get_DailyFileMissingAColumn(t *testing.T) {
+	weekly := filesBundle(map[string]string{
+		secToFIGIs: `"FSYM_ID"|"BBG_ID"|"BBG_TICKER"` + "\n" +
+			`"MLKNP9-L"|"BBG000BDN0W4"|"MKS LN"`,
+	})
+	monday := filesBundle(map[string]string{
+		secToFIGIs: `"FSYM_ID"|"BBG_ID"` + "\n" +
+			`"MLKNP9-L"|"BBG000BDN0W5"`,
+	})
+
+	_, err := newDeltaBundle(weekly, []resourceBundle{monday}).get(secToFIGIs)
+
+	assert.Equal(t, errMissingColumn, errors.Cause(err))
+}
+
+func TestDeltaBundle_Get_FileIsMergedOnce(t *testing.T) {
+	files := map[string]string{
+		secToFIGIs: `"FSYM_ID"|"BBG_ID"|"BBG_TICKER"` + "\n" +
+			`"MLKNP9-L"|"BBG000BDN0W4"|"MKS LN"`,
+	}
+	reads := 0
+	weekly := &mockResourceBundle{
+		mockGet: func(name string) (io.ReadCloser, error) {
+			reads++
+			return ioutil.NopCloser(strings.NewReader(files[name])), nil
+		},
+	}
+	d := newDeltaBundle(weekly, []resourceBundle{filesBundle(map[string]string{})})
+
+	for i := 0; i < 2; i++ {
+		r, err := d.get(secToFIGIs)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		r.Close()
+		assert.Equal(t, files[secToFIGIs]+"\n", string(body))
+	}
+	assert.Equal(t, 1, reads)
+}
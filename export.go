@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// export formats, as named in the format query parameter
+const (
+	exportNDJSON = "ndjson"
+	exportCSV    = "csv"
+)
+
+var exportContentTypes = map[string]string{
+	exportNDJSON: "application/x-ndjson",
+	exportCSV:    "text/csv; charset=utf-8",
+}
+
+var exportCSVHeader = []string{"uuid", "prefLabel", "factsetIdentifier", "figiCode", "issuedBy", "ticker", "exchange", "currency", "issuerCountry", "listings"}
+
+// exportMediaRanges are the media ranges matching each export format, from the most to the least specific.
+var exportMediaRanges = map[string][]string{
+	exportNDJSON: {"application/x-ndjson", "application/*", "*/*"},
+	exportCSV:    {"text/csv", "text/*", "*/*"},
+}
+
+// exportFormat picks the format of an export from the format query parameter, or else from the Accept header:
+// the format of highest quality, the first listed among equal qualities. NDJSON is the default.
+// It returns false when the requested format is not supported.
+func exportFormat(r *http.Request) (string, bool) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		_, supported := exportContentTypes[format]
+		return format, supported
+	}
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return exportNDJSON, true
+	}
+	mediaRanges := parseQualityValues(accept)
+	best, bestQ, bestPosition := "", 0.0, 0
+	for _, format := range []string{exportNDJSON, exportCSV} {
+		q, position, found := quality(mediaRanges, exportMediaRanges[format]...)
+		if found && q > 0 && (q > bestQ || q == bestQ && position < bestPosition) {
+			best, bestQ, bestPosition = format, q, position
+		}
+	}
+	return best, best != ""
+}
+
+// acceptsGzip tells whether the Accept-Encoding header allows gzip, with a quality not lower than that of identity.
+func acceptsGzip(r *http.Request) bool {
+	codings := parseQualityValues(r.Header.Get("Accept-Encoding"))
+	gzipQ, gzipPosition, found := quality(codings, "gzip", "*")
+	if !found || gzipQ == 0 {
+		return false
+	}
+	identityQ, identityPosition, found := quality(codings, "identity")
+	return !found || gzipQ > identityQ || gzipQ == identityQ && gzipPosition < identityPosition
+}
+
+// qualityValue is an entry of an Accept or Accept-Encoding header, a media range or a content coding, with its quality.
+type qualityValue struct {
+	value string
+	q     float64
+}
+
+// parseQualityValues parses the comma-separated entries of a header. An entry without a q parameter has quality 1,
+// and one whose q parameter is not a number between 0 and 1 has quality 0.
+func parseQualityValues(header string) []qualityValue {
+	var entries []qualityValue
+	for _, entry := range strings.Split(header, ",") {
+		params := strings.Split(entry, ";")
+		value := strings.ToLower(strings.TrimSpace(params[0]))
+		if value == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			nameValue := strings.SplitN(param, "=", 2)
+			if len(nameValue) != 2 || strings.ToLower(strings.TrimSpace(nameValue[0])) != "q" {
+				continue
+			}
+			var err error
+			if q, err = strconv.ParseFloat(strings.TrimSpace(nameValue[1]), 64); err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+		}
+		entries = append(entries, qualityValue{value: value, q: q})
+	}
+	return entries
+}
+
+// quality returns the quality and the position in the header of the first of the values it lists, the values being
+// given from the most to the least specific. It returns false when the header lists none of them.
+func quality(entries []qualityValue, values ...string) (float64, int, bool) {
+	for _, value := range values {
+		for i, entry := range entries {
+			if entry.value == value {
+				return entry.q, i, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// Export streams every financial instrument of the dataset in UUID order, as NDJSON or CSV,
+// gzip-compressed when the client accepts it.
+func (h *httpHandler) Export(w http.ResponseWriter, r *http.Request) {
+	ds := h.dataset(w)
+	if ds == nil {
+		return
+	}
+
+	format, ok := exportFormat(r)
+	if !ok {
+		writeMessage(w, http.StatusNotAcceptable, "Export formats are ndjson and csv")
+		return
+	}
+	encoding := "identity"
+	if acceptsGzip(r) {
+		encoding = "gzip"
+	}
+
+	w.Header().Set("Vary", "Accept, Accept-Encoding")
+	if cached(w, r, ds, entityTag(ds.version, []byte("export|"+format+"|"+encoding)), cacheListings) {
+		return
+	}
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", `attachment; filename="financial-instruments-`+ds.resourcesFolder+"."+format+`"`)
+
+	bw := bufio.NewWriter(w)
+	var out io.Writer = bw
+	var gw *gzip.Writer
+	if encoding == "gzip" {
+		w.Header().Set("Content-Encoding", "gzip")
+		gw = gzip.NewWriter(bw)
+		out = gw
+	}
+
+	var err error
+	if format == exportCSV {
+		err = exportAsCSV(out, ds)
+	} else {
+		err = exportAsNDJSON(out, ds)
+	}
+	if err == nil && gw != nil {
+		err = gw.Close()
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		warnLogger.Printf("Could not write [%s] export: [%v]", format, err)
+	}
+}
+
+func exportAsNDJSON(out io.Writer, ds *dataset) error {
+	enc := json.NewEncoder(out)
+	for _, UUID := range ds.IDs() {
+		fi, _ := ds.Read(UUID)
+		if err := enc.Encode(toUppFI(UUID, fi)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportAsCSV writes a row per financial instrument, with its listings in the same JSON as in the uppFI representation.
+func exportAsCSV(out io.Writer, ds *dataset) error {
+	cw := csv.NewWriter(out)
+	if err := cw.Write(exportCSVHeader); err != nil {
+		return err
+	}
+	for _, UUID := range ds.IDs() {
+		fi, _ := ds.Read(UUID)
+		listings := ""
+		if len(fi.listings) > 0 {
+			listings = listingsJSON(fi.listings)
+		}
+		err := cw.Write([]string{UUID, fi.securityName, fi.securityID, fi.figiCode, fi.orgID,
+			fi.ticker, fi.exchange, fi.currency, fi.issuerCountry, listings})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport(t *testing.T) {
+	m := map[string]financialInstrument{
+		"8404dbec-2423-322d-9afa-f92e553e53b6": {
+			figiCode:      "BBG000BDN0W4",
+			securityID:    "GG9B0P-S",
+			orgID:         "21fbf032-23e7-34d3-970c-45432b455fd9",
+			securityName:  "Marks & Spencer Group Plc",
+			ticker:        "MKS LN",
+			exchange:      "LON",
+			currency:      "GBP",
+			issuerCountry: "GB",
+			listings:      []listing{{listingID: "MLKNP9-L", figiCode: "BBG000BDN0W4", ticker: "MKS LN", exchange: "LON", currency: "GBP", primary: true}},
+		},
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": {
+			figiCode:     "BBG000JPVHS1",
+			securityID:   "JBP7Z8-S",
+			orgID:        "ea90a425-73be-33c5-9aa4-939c9a46b87a",
+			securityName: "Industrija Precizne Mehanike AD, Beograd",
+		},
+	}
+	ndjson := `{"uuid":"404c8329-3f8e-348e-ba32-cf3eb2c1ffed","prefLabel":"Industrija Precizne Mehanike AD, Beograd","alternativeIdentifiers":{"uuids":["404c8329-3f8e-348e-ba32-cf3eb2c1ffed"],"factsetIdentifier":"JBP7Z8-S","figiCode":"BBG000JPVHS1"},"issuedBy":"ea90a425-73be-33c5-9aa4-939c9a46b87a"}` + "\n" +
+		`{"uuid":"8404dbec-2423-322d-9afa-f92e553e53b6","prefLabel":"Marks \u0026 Spencer Group Plc","alternativeIdentifiers":{"uuids":["8404dbec-2423-322d-9afa-f92e553e53b6"],"factsetIdentifier":"GG9B0P-S","figiCode":"BBG000BDN0W4"},"issuedBy":"21fbf032-23e7-34d3-970c-45432b455fd9","ticker":"MKS LN","exchange":"LON","currency":"GBP","issuerCountry":"GB","listings":[{"factsetIdentifier":"MLKNP9-L","figiCode":"BBG000BDN0W4","ticker":"MKS LN","exchange":"LON","currency":"GBP","primary":true}]}` + "\n"
+	csv := "uuid,prefLabel,factsetIdentifier,figiCode,issuedBy,ticker,exchange,currency,issuerCountry,listings\n" +
+		`404c8329-3f8e-348e-ba32-cf3eb2c1ffed,"Industrija Precizne Mehanike AD, Beograd",JBP7Z8-S,BBG000JPVHS1,ea90a425-73be-33c5-9aa4-939c9a46b87a,,,,,` + "\n" +
+		`8404dbec-2423-322d-9afa-f92e553e53b6,Marks & Spencer Group Plc,GG9B0P-S,BBG000BDN0W4,21fbf032-23e7-34d3-970c-45432b455fd9,MKS LN,LON,GBP,GB,"[{""factsetIdentifier"":""MLKNP9-L"",""figiCode"":""BBG000BDN0W4"",""ticker"":""MKS LN"",""exchange"":""LON"",""currency"":""GBP"",""primary"":true}]"` + "\n"
+
+	var testCases = []struct {
+		name                string
+		query               string
+		accept              string
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{name: "ndjson by default", expectedCode: 200, expectedContentType: "application/x-ndjson", expectedBody: ndjson},
+		{name: "csv by query parameter", query: "?format=csv", accept: "application/x-ndjson", expectedCode: 200, expectedContentType: "text/csv; charset=utf-8", expectedBody: csv},
+		{name: "csv by accept header", accept: "text/csv;q=0.9, application/json;q=0.5", expectedCode: 200, expectedContentType: "text/csv; charset=utf-8", expectedBody: csv},
+		{name: "ndjson by accept header", accept: "application/x-ndjson", expectedCode: 200, expectedContentType: "application/x-ndjson", expectedBody: ndjson},
+		{name: "refused csv", accept: "text/csv;q=0, application/x-ndjson", expectedCode: 200, expectedContentType: "application/x-ndjson", expectedBody: ndjson},
+		{name: "highest quality first", accept: "application/x-ndjson;q=0.5, text/csv", expectedCode: 200, expectedContentType: "text/csv; charset=utf-8", expectedBody: csv},
+		{name: "equal qualities, first listed", accept: "text/*, application/x-ndjson", expectedCode: 200, expectedContentType: "text/csv; charset=utf-8", expectedBody: csv},
+		{name: "wildcard but refused ndjson", accept: "application/x-ndjson;q=0, */*;q=0.1", expectedCode: 200, expectedContentType: "text/csv; charset=utf-8", expectedBody: csv},
+		{name: "every format refused", accept: "text/csv;q=0, application/x-ndjson;q=0", expectedCode: 406},
+		{name: "unknown format", query: "?format=xml", expectedCode: 406},
+		{name: "unacceptable", accept: "application/xml", expectedCode: 406},
+	}
+
+	h := httpHandler{fiService: serving(&fiServiceImpl{}, "2017-08-10", m)}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/transformers/financial-instruments/__export"+tc.query, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+
+			h.Export(w, req)
+
+			require.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tc.expectedContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+			assert.NotEmpty(t, w.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestExport_Gzip(t *testing.T) {
+	h := httpHandler{fiService: serving(&fiServiceImpl{}, "2017-08-10", map[string]financialInstrument{"foo": {securityID: "TVKI-123"}})}
+	plain := httptest.NewRecorder()
+	h.Export(plain, httptest.NewRequest("GET", "/transformers/financial-instruments/__export?format=csv", nil))
+
+	req := httptest.NewRequest("GET", "/transformers/financial-instruments/__export?format=csv", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	w := httptest.NewRecorder()
+	h.Export(w, req)
+
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, `attachment; filename="financial-instruments-2017-08-10.csv"`, w.Header().Get("Content-Disposition"))
+	assert.NotEqual(t, plain.Header().Get("ETag"), w.Header().Get("ETag"))
+	gr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(gr)
+	require.NoError(t, err)
+	assert.Equal(t, plain.Body.String(), string(body))
+}
+
+func TestExport_GzipRefused(t *testing.T) {
+	h := httpHandler{fiService: serving(&fiServiceImpl{}, "2017-08-10", map[string]financialInstrument{"foo": {securityID: "TVKI-123"}})}
+
+	for _, acceptEncoding := range []string{"gzip;q=0", "identity, gzip;q=0", "*;q=0", "identity;q=0.9, gzip;q=0.5"} {
+		req := httptest.NewRequest("GET", "/transformers/financial-instruments/__export?format=csv", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		h.Export(w, req)
+
+		require.Equal(t, 200, w.Code, acceptEncoding)
+		assert.Empty(t, w.Header().Get("Content-Encoding"), acceptEncoding)
+		assert.Equal(t, "uuid,prefLabel,factsetIdentifier,figiCode,issuedBy,ticker,exchange,currency,issuerCountry,listings\nfoo,,TVKI-123,,,,,,,\n", w.Body.String(), acceptEncoding)
+	}
+}
+
+func TestExport_NotInitialisedService_StatusServiceUnavailable(t *testing.T) {
+	h := httpHandler{fiService: &fiServiceImpl{}}
+	w := httptest.NewRecorder()
+
+	h.Export(w, httptest.NewRequest("GET", "/transformers/financial-instruments/__export", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"bufio"
+	"crypto/subtle"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -17,6 +23,20 @@ type uppFI struct {
 	PrefLabel      string         `json:"prefLabel"`
 	AlternativeIDs alternativeIDs `json:"alternativeIdentifiers"`
 	IssuedBy       string         `json:"issuedBy"`
+	Ticker         string         `json:"ticker,omitempty"`
+	Exchange       string         `json:"exchange,omitempty"`
+	Currency       string         `json:"currency,omitempty"`
+	IssuerCountry  string         `json:"issuerCountry,omitempty"`
+	Listings       []uppListing   `json:"listings,omitempty"`
+}
+
+type uppListing struct {
+	FactsetID string `json:"factsetIdentifier"`
+	FIGI      string `json:"figiCode,omitempty"`
+	Ticker    string `json:"ticker,omitempty"`
+	Exchange  string `json:"exchange,omitempty"`
+	Currency  string `json:"currency,omitempty"`
+	Primary   bool   `json:"primary"`
 }
 
 type alternativeIDs struct {
@@ -29,64 +49,331 @@ type apiUrl struct {
 	APIURL string `json:"apiUrl"`
 }
 
+type resourcesFolders struct {
+	Available []string `json:"available"`
+	Loaded    string   `json:"loaded"`
+	Pinned    string   `json:"pinned,omitempty"`
+}
+
+type message struct {
+	Message string `json:"message"`
+}
+
 type httpHandler struct {
-	fiService fiService
-	baseUrl   string
+	fiService     fiService
+	baseUrl       string
+	reloader      *reloader
+	adminAPIKey   string
+	maxDatasetAge time.Duration
 }
 
-func (h *httpHandler) Count(w http.ResponseWriter, r *http.Request) {
-	s := h.fiService
+const apiKeyHeader = "X-Api-Key"
 
-	if !s.IsInitialised() {
+// withAdminAuth only lets through requests carrying the configured admin API key.
+// When no key is configured, the admin endpoints are disabled altogether.
+func (h *httpHandler) withAdminAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.adminAPIKey == "" {
+			writeMessage(w, http.StatusForbidden, "Admin endpoints are disabled")
+			return
+		}
+		key := r.Header.Get(apiKeyHeader)
+		if subtle.ConstantTimeCompare([]byte(key), []byte(h.adminAPIKey)) != 1 {
+			warnLogger.Printf("Rejected unauthenticated request to [%s]", r.URL.Path)
+			writeMessage(w, http.StatusUnauthorized, "Missing or invalid "+apiKeyHeader+" header")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// dataset returns the dataset the request is served from, and sets its version on the response,
+// so that a request reading several things from the dataset sees them all in the same version.
+// While no dataset has been loaded it responds with a 503 and returns nil.
+func (h *httpHandler) dataset(w http.ResponseWriter) *dataset {
+	ds := h.fiService.Dataset()
+	if ds == nil {
 		w.WriteHeader(http.StatusServiceUnavailable)
-		return
+		return nil
 	}
+	w.Header().Set(datasetVersionHeader, ds.version)
+	return ds
+}
 
-	_, err := w.Write([]byte(strconv.Itoa(s.Count())))
-	if err != nil {
-		warnLogger.Printf("Could not write /count response: [%v]", err)
+func (h *httpHandler) Count(w http.ResponseWriter, r *http.Request) {
+	ds := h.dataset(w)
+	if ds == nil {
+		return
 	}
+
+	writeCached(w, r, ds, []byte(strconv.Itoa(ds.Count())), cacheListings)
 }
 
 func (h *httpHandler) IDs(w http.ResponseWriter, r *http.Request) {
-	s := h.fiService
+	ds := h.dataset(w)
+	if ds == nil {
+		return
+	}
 
-	if !s.IsInitialised() {
-		w.WriteHeader(http.StatusServiceUnavailable)
+	ids, ok := page(w, r, ds.IDs())
+	if !ok {
+		return
+	}
+	// the page only depends on the dataset and the query, so it is not encoded to compute its ETag
+	if cached(w, r, ds, entityTag(ds.version, []byte(r.URL.RequestURI())), cacheListings) {
 		return
 	}
 
 	w.Header().Add("Content-Type", "application/json")
 
-	enc := json.NewEncoder(w)
-	for _, uid := range s.IDs() {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, uid := range ids {
 		err := enc.Encode(id{ID: uid})
 		if err != nil {
 			warnLogger.Printf("Could not encode uid: [%s]. Err: [%v]", uid, err)
 			continue
 		}
 	}
+	if err := bw.Flush(); err != nil {
+		warnLogger.Printf("Could not write /ids response: [%v]", err)
+	}
 }
 
-func (h *httpHandler) Read(w http.ResponseWriter, r *http.Request) {
-	s := h.fiService
+// page applies the "after" cursor and the "limit" query parameters to the sorted UUIDs.
+// When more UUIDs follow the returned page, a Link header pointing to the next page is set.
+func page(w http.ResponseWriter, r *http.Request, ids []string) ([]string, bool) {
+	q := r.URL.Query()
 
-	if !s.IsInitialised() {
-		w.WriteHeader(http.StatusServiceUnavailable)
+	start := 0
+	if after := q.Get("after"); after != "" {
+		start = sort.SearchStrings(ids, after)
+		if start < len(ids) && ids[start] == after {
+			start++
+		}
+	}
+
+	end := len(ids)
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			writeMessage(w, http.StatusBadRequest, "limit must be a positive integer")
+			return nil, false
+		}
+		if start+limit < end {
+			end = start + limit
+			q.Set("after", ids[end-1])
+			next := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+		}
+	}
+	return ids[start:end], true
+}
+
+func (h *httpHandler) Status(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(h.fiService.Status())
+	if err != nil {
+		warnLogger.Printf("Could not write /status response: [%v]", err)
+	}
+}
+
+func (h *httpHandler) FIGIConflicts(w http.ResponseWriter, r *http.Request) {
+	ds := h.dataset(w)
+	if ds == nil {
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	writeJSON(w, r, ds, ds.FIGIConflicts(), cacheReports)
+}
+
+func (h *httpHandler) TransformReport(w http.ResponseWriter, r *http.Request) {
+	ds := h.dataset(w)
+	if ds == nil {
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	writeJSON(w, r, ds, ds.TransformReport(), cacheReports)
+}
+
+func (h *httpHandler) Changes(w http.ResponseWriter, r *http.Request) {
+	ds := h.dataset(w)
+	if ds == nil {
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	writeJSON(w, r, ds, ds.Changes(), cacheReports)
+}
+
+func (h *httpHandler) Folders(w http.ResponseWriter, r *http.Request) {
+	folders, err := h.fiService.Folders()
+	if err != nil {
+		errorLogger.Printf("Could not list resources folders: [%v]", err)
+		writeMessage(w, http.StatusInternalServerError, "Could not list resources folders")
+		return
+	}
+	status := h.fiService.Status()
+
+	w.Header().Add("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(resourcesFolders{
+		Available: folders,
+		Loaded:    status.ResourcesFolder,
+		Pinned:    status.PinnedFolder,
+	})
+	if err != nil {
+		warnLogger.Printf("Could not write /folders response: [%v]", err)
+	}
+}
+
+func (h *httpHandler) PinFolder(w http.ResponseWriter, r *http.Request) {
+	folder := mux.Vars(r)["folder"]
+
+	err := h.fiService.Pin(folder)
+	switch err {
+	case nil:
+	case errInvalidResourcesFolder:
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	case errResourcesFolderNotFound:
+		writeMessage(w, http.StatusNotFound, err.Error())
+		return
+	default:
+		errorLogger.Printf("Could not pin resources folder [%s]: [%v]", folder, err)
+		writeMessage(w, http.StatusInternalServerError, "Could not pin resources folder")
+		return
+	}
+
+	h.refreshInBackground()
+	writeMessage(w, http.StatusAccepted, "Loading resources folder "+folder)
+}
+
+func (h *httpHandler) UnpinFolder(w http.ResponseWriter, r *http.Request) {
+	h.fiService.Unpin()
+
+	h.refreshInBackground()
+	writeMessage(w, http.StatusAccepted, "Loading latest resources folder")
+}
+
+func (h *httpHandler) Reload(w http.ResponseWriter, r *http.Request) {
+	job, started := h.reloader.start()
+
+	status := http.StatusAccepted
+	if !started {
+		status = http.StatusConflict
+	}
+	writeJob(w, status, job)
+}
+
+func (h *httpHandler) ReloadJob(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["jobID"]
+
+	job, present := h.reloader.job(id)
+	if !present {
+		writeMessage(w, http.StatusNotFound, "Reload job "+id+" does not exist")
+		return
+	}
+	writeJob(w, http.StatusOK, job)
+}
+
+func writeJob(w http.ResponseWriter, status int, job reloadJob) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		warnLogger.Printf("Could not write reload job [%s]: [%v]", job.ID, err)
+	}
+}
+
+func (h *httpHandler) refreshInBackground() {
+	go func() {
+		if err := h.fiService.Refresh(); err != nil {
+			errorLogger.Printf("Could not refresh FIs: [%v]", err)
+		}
+	}()
+}
+
+func writeMessage(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(message{Message: msg}); err != nil {
+		warnLogger.Printf("Could not write message [%s]: [%v]", msg, err)
+	}
+}
+
+func (h *httpHandler) Read(w http.ResponseWriter, r *http.Request) {
+	ds := h.dataset(w)
+	if ds == nil {
 		return
 	}
 
 	w.Header().Add("Content-Type", "application/json")
 
 	id := mux.Vars(r)["id"]
-	fi, present := s.Read(id)
+	fi, present := ds.Read(id)
 
 	if !present {
 		infoLogger.Printf("FI with uuid [%s] does not exist", id)
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
-	uppFi := uppFI{
+	writeJSON(w, r, ds, toUppFI(id, fi), cacheRecords)
+}
+
+func (h *httpHandler) ReadByFIGI(w http.ResponseWriter, r *http.Request) {
+	h.readByAlternativeID(w, r, "figi", mux.Vars(r)["figi"], (*dataset).ReadByFIGI)
+}
+
+func (h *httpHandler) ReadByFactsetID(w http.ResponseWriter, r *http.Request) {
+	h.readByAlternativeID(w, r, "factset id", mux.Vars(r)["securityID"], (*dataset).ReadByFactsetID)
+}
+
+func (h *httpHandler) readByAlternativeID(w http.ResponseWriter, r *http.Request, idType string, altID string, read func(*dataset, string) (string, financialInstrument, bool)) {
+	ds := h.dataset(w)
+	if ds == nil {
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	id, fi, present := read(ds, altID)
+	if !present {
+		infoLogger.Printf("FI with %s [%s] does not exist", idType, altID)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, ds, toUppFI(id, fi), cacheRecords)
+}
+
+func (h *httpHandler) ReadByIssuer(w http.ResponseWriter, r *http.Request) {
+	ds := h.dataset(w)
+	if ds == nil {
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	orgUUID := mux.Vars(r)["orgUUID"]
+	uppFIs := []uppFI{}
+	for _, id := range ds.IssuedBy(orgUUID) {
+		if fi, present := ds.Read(id); present {
+			uppFIs = append(uppFIs, toUppFI(id, fi))
+		}
+	}
+
+	if len(uppFIs) == 0 {
+		infoLogger.Printf("No FI issued by [%s] exists", orgUUID)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, ds, uppFIs, cacheRecords)
+}
+
+func toUppFI(id string, fi financialInstrument) uppFI {
+	return uppFI{
 		UUID:      id,
 		PrefLabel: fi.securityName,
 		AlternativeIDs: alternativeIDs{
@@ -94,36 +381,78 @@ func (h *httpHandler) Read(w http.ResponseWriter, r *http.Request) {
 			FactsetID: fi.securityID,
 			FIGI:      fi.figiCode,
 		},
-		IssuedBy: fi.orgID,
+		IssuedBy:      fi.orgID,
+		Ticker:        fi.ticker,
+		Exchange:      fi.exchange,
+		Currency:      fi.currency,
+		IssuerCountry: fi.issuerCountry,
+		Listings:      toUppListings(fi.listings),
+	}
+}
+
+func toUppListings(listings []listing) []uppListing {
+	if len(listings) == 0 {
+		return nil
+	}
+	uppListings := make([]uppListing, 0, len(listings))
+	for _, l := range listings {
+		uppListings = append(uppListings, uppListing{
+			FactsetID: l.listingID,
+			FIGI:      l.figiCode,
+			Ticker:    l.ticker,
+			Exchange:  l.exchange,
+			Currency:  l.currency,
+			Primary:   l.primary,
+		})
 	}
-	err := json.NewEncoder(w).Encode(uppFi)
+	return uppListings
+}
+
+// writeJSON encodes a value read from the dataset as a cacheable response, with the same trailing newline as json.Encoder.
+func writeJSON(w http.ResponseWriter, r *http.Request, ds *dataset, v interface{}, cacheControl string) {
+	body, err := json.Marshal(v)
 	if err != nil {
-		warnLogger.Printf("Could not return fi with uuid [%s]. Resource: [%v]. Err: [%v]", id, fi, err)
+		warnLogger.Printf("Could not encode [%s] response: [%v]", r.URL.Path, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	writeCached(w, r, ds, append(body, '\n'), cacheControl)
 }
 
 func (h *httpHandler) getFinancialInstruments(w http.ResponseWriter, r *http.Request) {
-	s := h.fiService
+	ds := h.dataset(w)
+	if ds == nil {
+		return
+	}
 
-	if !s.IsInitialised() {
-		w.WriteHeader(http.StatusServiceUnavailable)
+	ids, ok := page(w, r, ds.IDs())
+	if !ok {
+		return
+	}
+	// the API URLs also depend on the base URL, which can differ between instances
+	if cached(w, r, ds, entityTag(ds.version, []byte(h.baseUrl+r.URL.RequestURI())), cacheListings) {
 		return
 	}
 
 	w.Header().Add("Content-Type", "application/json")
 
-	var apiUrls = []apiUrl{}
-	for _, uuid := range s.IDs() {
-		apiUrl := apiUrl{APIURL: h.baseUrl + uuid}
-		apiUrls = append(apiUrls, apiUrl)
+	// the array is written element by element rather than encoded in one go, to avoid buffering it whole
+	bw := bufio.NewWriter(w)
+	bw.WriteString("[")
+	separator := ""
+	for _, uuid := range ids {
+		b, err := json.Marshal(apiUrl{APIURL: h.baseUrl + uuid})
+		if err != nil {
+			warnLogger.Printf("Error on json encoding=%v\n", err)
+			continue
+		}
+		bw.WriteString(separator)
+		bw.Write(b)
+		separator = ","
 	}
+	bw.WriteString("]\n")
 
-	err := json.NewEncoder(w).Encode(apiUrls)
-
-	if err != nil {
-		warnLogger.Printf("Error on json encoding=%v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	if err := bw.Flush(); err != nil {
+		warnLogger.Printf("Could not write financial instruments response: [%v]", err)
 	}
 }
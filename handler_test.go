@@ -4,7 +4,9 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/require"
@@ -51,7 +53,7 @@ func TestCount_FinancialInstrumentsMapIsNotNil_OkStatusCodeAndResponseBodyShowsC
 	}
 
 	for _, tc := range testCases {
-		fi := &fiServiceImpl{financialInstruments: tc.fiMap}
+		fi := serving(&fiServiceImpl{}, "2017-08-10", tc.fiMap)
 		h := httpHandler{fiService: fi}
 		w := httptest.NewRecorder()
 		h.Count(w, req)
@@ -92,9 +94,8 @@ func TestIds_FinancialInstrumentsMapIsNotNil_IdsInStreamingJsonFormatAreReturned
 		},
 		{
 			fiMap: map[string]financialInstrument{"foo": {}, "bar": {}},
-			//map order is random, hence the multiple valid responses
+			//ids are sorted
 			expected: []string{
-				`{"id":"foo"}` + "\n" + `{"id":"bar"}` + "\n",
 				`{"id":"bar"}` + "\n" + `{"id":"foo"}` + "\n",
 			},
 		},
@@ -106,7 +107,7 @@ func TestIds_FinancialInstrumentsMapIsNotNil_IdsInStreamingJsonFormatAreReturned
 	}
 
 	for _, tc := range testCases {
-		fi := &fiServiceImpl{financialInstruments: tc.fiMap}
+		fi := serving(&fiServiceImpl{}, "2017-08-10", tc.fiMap)
 		h := httpHandler{fiService: fi}
 		w := httptest.NewRecorder()
 		h.IDs(w, req)
@@ -139,11 +140,9 @@ func TestRead_FinancialInstrumentsMapIsNil_StatusServiceUnavailable(t *testing.T
 // mux package doesn't provide a way to mock path params, therefore we have to set up a test server with a router
 
 func TestId_RequestedFinancialInstrumentDoesNotExist_StatusNotFound(t *testing.T) {
-	s := &fiServiceImpl{
-		financialInstruments: map[string]financialInstrument{
-			"foo": {},
-		},
-	}
+	s := serving(&fiServiceImpl{}, "2017-08-10", map[string]financialInstrument{
+		"foo": {},
+	})
 	h := httpHandler{fiService: s}
 
 	r := mux.NewRouter()
@@ -161,16 +160,22 @@ func TestId_RequestedFinancialInstrumentDoesNotExist_StatusNotFound(t *testing.T
 }
 
 func TestId_FinancialInstrumentExists_OkStatusAndCorrectFIReturned(t *testing.T) {
-	s := &fiServiceImpl{
-		financialInstruments: map[string]financialInstrument{
-			"foo": {
-				figiCode:     "BBG01234",
-				securityID:   "TVKI-123",
-				orgID:        "012AF-E",
-				securityName: "LIG SPECIAL PURPOSE ACQ 2ND CO  ORD",
+	s := serving(&fiServiceImpl{}, "2017-08-10", map[string]financialInstrument{
+		"foo": {
+			figiCode:      "BBG01234",
+			securityID:    "TVKI-123",
+			orgID:         "012AF-E",
+			securityName:  "LIG SPECIAL PURPOSE ACQ 2ND CO  ORD",
+			ticker:        "LIGS KS",
+			exchange:      "KRX",
+			currency:      "KRW",
+			issuerCountry: "KR",
+			listings: []listing{
+				{listingID: "TVKI-L1", figiCode: "BBG01234", ticker: "LIGS KS", exchange: "KRX", currency: "KRW", primary: true},
+				{listingID: "TVKI-L2", exchange: "OTC", currency: "USD"},
 			},
 		},
-	}
+	})
 	r := mux.NewRouter()
 	h := httpHandler{fiService: s}
 
@@ -185,12 +190,14 @@ func TestId_FinancialInstrumentExists_OkStatusAndCorrectFIReturned(t *testing.T)
 	}
 
 	require.Equal(t, 200, resp.StatusCode, "Wrong HTTP response status code.")
+	require.NotEmpty(t, resp.Header.Get(datasetVersionHeader))
+	require.Equal(t, s.Dataset().Version(), resp.Header.Get(datasetVersionHeader), "Wrong dataset version.")
 
 	rBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		t.Fatalf("Failure: [%v]", err)
 	}
-	expected := `{"uuid":"foo","prefLabel":"LIG SPECIAL PURPOSE ACQ 2ND CO  ORD","alternativeIdentifiers":{"uuids":["foo"],"factsetIdentifier":"TVKI-123","figiCode":"BBG01234"},"issuedBy":"012AF-E"}` + "\n"
+	expected := `{"uuid":"foo","prefLabel":"LIG SPECIAL PURPOSE ACQ 2ND CO  ORD","alternativeIdentifiers":{"uuids":["foo"],"factsetIdentifier":"TVKI-123","figiCode":"BBG01234"},"issuedBy":"012AF-E","ticker":"LIGS KS","exchange":"KRX","currency":"KRW","issuerCountry":"KR","listings":[{"factsetIdentifier":"TVKI-L1","figiCode":"BBG01234","ticker":"LIGS KS","exchange":"KRX","currency":"KRW","primary":true},{"factsetIdentifier":"TVKI-L2","exchange":"OTC","currency":"USD","primary":false}]}` + "\n"
 	actual := string(rBody)
 
 	require.Equal(t, expected, actual, "Wrong FI.")
@@ -230,9 +237,8 @@ func TestGetFinancialInstruments_FinancialInstrumentsMapIsNotNil_ApiIdsInStreami
 		},
 		{
 			fiMap: map[string]financialInstrument{uuid1: {}, uuid2: {}},
-			//map order is random, hence the multiple valid responses
+			//ids are sorted
 			expected: []string{
-				`[{"apiUrl":"` + baseUrl + uuid1 + `"},{"apiUrl":"` + baseUrl + uuid2 + `"}]` + "\n",
 				`[{"apiUrl":"` + baseUrl + uuid2 + `"},{"apiUrl":"` + baseUrl + uuid1 + `"}]` + "\n",
 			},
 		},
@@ -244,7 +250,7 @@ func TestGetFinancialInstruments_FinancialInstrumentsMapIsNotNil_ApiIdsInStreami
 	}
 
 	for _, tc := range testCases {
-		fi := &fiServiceImpl{financialInstruments: tc.fiMap}
+		fi := serving(&fiServiceImpl{}, "2017-08-10", tc.fiMap)
 		h := httpHandler{fiService: fi, baseUrl: baseUrl}
 		w := httptest.NewRecorder()
 		h.getFinancialInstruments(w, req)
@@ -266,3 +272,428 @@ func equals(actual string, expected []string) bool {
 	}
 	return false
 }
+
+func TestStatus_ServiceNotInitialised_LastFailureIsReturned(t *testing.T) {
+	failedAt := time.Date(2017, time.August, 10, 9, 30, 0, 0, time.UTC)
+	fis := &fiServiceImpl{
+		status: loadStatus{Attempts: 2, LastFailure: failedAt, LastError: "S3 is not reachable"},
+	}
+	h := httpHandler{fiService: fis}
+
+	req, err := http.NewRequest("GET", "http://fiTransformer/__status", nil)
+	if err != nil {
+		t.Fatalf("Failure in setting up the test request: [%v]", err)
+	}
+
+	w := httptest.NewRecorder()
+
+	h.Status(w, req)
+
+	require.Equal(t, 200, w.Code)
+	expected := `{"resourcesFolder":"","attempts":2,"lastSuccess":"0001-01-01T00:00:00Z","lastFailure":"2017-08-10T09:30:00Z","lastError":"S3 is not reachable"}` + "\n"
+	require.Equal(t, expected, w.Body.String())
+}
+
+func TestRead_LocalDataset_FIIsServedOverHTTP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fis_test_data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeLocalDataset(t, dir, "2017-08-10")
+
+	l := newFSLoader(dir)
+	s := &fiServiceImpl{fit: &fiTransformerImpl{loader: &l, parser: newFIParser(defaultSelectionRules)}}
+	require.NoError(t, s.Init())
+
+	r := mux.NewRouter()
+	h := httpHandler{fiService: s}
+	r.HandleFunc("/{id}", h.Read)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/404c8329-3f8e-348e-ba32-cf3eb2c1ffed")
+	if err != nil {
+		t.Fatalf("Failure: [%v]", err)
+	}
+	defer resp.Body.Close()
+
+	require.Equal(t, 200, resp.StatusCode, "Wrong HTTP response status code.")
+
+	rBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failure: [%v]", err)
+	}
+	expected := `{"uuid":"404c8329-3f8e-348e-ba32-cf3eb2c1ffed","prefLabel":"Industrija Precizne Mehanike AD","alternativeIdentifiers":{"uuids":["404c8329-3f8e-348e-ba32-cf3eb2c1ffed"],"factsetIdentifier":"JBP7Z8-S","figiCode":"BBG000JPVHS1"},"issuedBy":"ea90a425-73be-33c5-9aa4-939c9a46b87a","ticker":"IPMB SG","exchange":"BEL","currency":"RSD","issuerCountry":"RS","listings":[{"factsetIdentifier":"M679DF-L","figiCode":"BBG000JPVHS1","ticker":"IPMB SG","exchange":"BEL","currency":"RSD","primary":true}]}` + "\n"
+	require.Equal(t, expected, string(rBody), "Wrong FI.")
+}
+
+func TestPinFolder_StatusCodes(t *testing.T) {
+	var testCases = []struct {
+		folder string
+		status int
+	}{
+		{"latest", http.StatusBadRequest},
+		{"2017-07-27", http.StatusNotFound},
+		{"2017-08-03", http.StatusAccepted},
+	}
+
+	for _, tc := range testCases {
+		s := &fiServiceImpl{
+			fit: &transformerMock{
+				mockListResourcesFolders: func() ([]string, error) {
+					return []string{"2017-08-03", "2017-08-10"}, nil
+				},
+				mockTransform: func() (map[string]financialInstrument, error) {
+					return map[string]financialInstrument{}, nil
+				},
+			},
+		}
+		h := httpHandler{fiService: s}
+		r := mux.NewRouter()
+		r.HandleFunc("/__folders/pinned/{folder}", h.PinFolder).Methods("PUT")
+
+		req, err := http.NewRequest("PUT", "/__folders/pinned/"+tc.folder, nil)
+		if err != nil {
+			t.Fatalf("Failure in setting up the test request: [%v]", err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		require.Equal(t, tc.status, w.Code, "Wrong HTTP response status code for folder [%s].", tc.folder)
+	}
+}
+
+func TestFolders_AvailableAndLoadedFoldersAreReturned(t *testing.T) {
+	s := &fiServiceImpl{
+		fit: &transformerMock{
+			mockListResourcesFolders: func() ([]string, error) {
+				return []string{"2017-08-03", "2017-08-10"}, nil
+			},
+		},
+		pinnedFolder: "2017-08-03",
+		status:       loadStatus{ResourcesFolder: "2017-08-03"},
+	}
+	h := httpHandler{fiService: s}
+
+	req, err := http.NewRequest("GET", "http://fiTransformer/__folders", nil)
+	if err != nil {
+		t.Fatalf("Failure in setting up the test request: [%v]", err)
+	}
+	w := httptest.NewRecorder()
+
+	h.Folders(w, req)
+
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, `{"available":["2017-08-03","2017-08-10"],"loaded":"2017-08-03","pinned":"2017-08-03"}`+"\n", w.Body.String())
+}
+
+func TestWithAdminAuth(t *testing.T) {
+	var testCases = []struct {
+		name       string
+		configured string
+		sent       string
+		status     int
+	}{
+		{"no key configured", "", "", http.StatusForbidden},
+		{"no key configured, key sent", "", "secret", http.StatusForbidden},
+		{"key missing", "secret", "", http.StatusUnauthorized},
+		{"wrong key", "secret", "guess", http.StatusUnauthorized},
+		{"right key", "secret", "secret", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := httpHandler{adminAPIKey: tc.configured}
+			req, err := http.NewRequest("POST", "http://fiTransformer/__reload", nil)
+			if err != nil {
+				t.Fatalf("Failure in setting up the test request: [%v]", err)
+			}
+			if tc.sent != "" {
+				req.Header.Set(apiKeyHeader, tc.sent)
+			}
+			w := httptest.NewRecorder()
+
+			h.withAdminAuth(func(w http.ResponseWriter, r *http.Request) {})(w, req)
+
+			require.Equal(t, tc.status, w.Code)
+		})
+	}
+}
+
+func TestReload_ReloadInProgress_ConflictStatusCode(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	r := newReloader(blockingService(release, nil))
+	h := httpHandler{reloader: r}
+
+	req, err := http.NewRequest("POST", "http://fiTransformer/__reload", nil)
+	if err != nil {
+		t.Fatalf("Failure in setting up the test request: [%v]", err)
+	}
+
+	w := httptest.NewRecorder()
+	h.Reload(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	w = httptest.NewRecorder()
+	h.Reload(w, req)
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Contains(t, w.Body.String(), `"status":"running"`)
+}
+
+func TestReloadJob_UnknownJob_StatusNotFound(t *testing.T) {
+	h := httpHandler{reloader: newReloader(&fiServiceImpl{})}
+	r := mux.NewRouter()
+	r.HandleFunc("/__reload/{jobID}", h.ReloadJob)
+
+	req, err := http.NewRequest("GET", "/__reload/foo", nil)
+	if err != nil {
+		t.Fatalf("Failure in setting up the test request: [%v]", err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAlternativeIDRoutes(t *testing.T) {
+	m := map[string]financialInstrument{
+		"foo": {
+			figiCode:     "BBG01234",
+			securityID:   "TVKI-123",
+			orgID:        "012AF-E",
+			securityName: "LIG SPECIAL PURPOSE ACQ 2ND CO  ORD",
+		},
+	}
+	s := serving(&fiServiceImpl{}, "2017-08-10", m)
+	h := httpHandler{fiService: s}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/figi/{figi}", h.ReadByFIGI)
+	r.HandleFunc("/factset/{securityID}", h.ReadByFactsetID)
+	r.HandleFunc("/issuer/{orgUUID}", h.ReadByIssuer)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	fooFI := `{"uuid":"foo","prefLabel":"LIG SPECIAL PURPOSE ACQ 2ND CO  ORD","alternativeIdentifiers":{"uuids":["foo"],"factsetIdentifier":"TVKI-123","figiCode":"BBG01234"},"issuedBy":"012AF-E"}`
+	var testCases = []struct {
+		path     string
+		status   int
+		expected string
+	}{
+		{"/figi/BBG01234", 200, fooFI + "\n"},
+		{"/figi/BBG99999", 404, ""},
+		{"/factset/TVKI-123", 200, fooFI + "\n"},
+		{"/factset/TVKI-999", 404, ""},
+		{"/issuer/012AF-E", 200, "[" + fooFI + "]\n"},
+		{"/issuer/999ZZ-E", 404, ""},
+	}
+
+	for _, tc := range testCases {
+		resp, err := http.Get(ts.URL + tc.path)
+		if err != nil {
+			t.Fatalf("Failure: [%v]", err)
+		}
+		rBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("Failure: [%v]", err)
+		}
+
+		require.Equal(t, tc.status, resp.StatusCode, "Wrong HTTP response status code for [%s].", tc.path)
+		require.Equal(t, tc.expected, string(rBody), "Wrong body for [%s].", tc.path)
+	}
+}
+
+func TestAlternativeIDRoutes_FinancialInstrumentsMapIsNil_StatusServiceUnavailable(t *testing.T) {
+	h := httpHandler{fiService: &fiServiceImpl{}}
+	req, err := http.NewRequest("GET", "http://fiTransformer/figi/BBG01234", nil)
+	if err != nil {
+		t.Fatalf("Failure in setting up the test request: [%v]", err)
+	}
+
+	for _, handle := range []http.HandlerFunc{h.ReadByFIGI, h.ReadByFactsetID, h.ReadByIssuer} {
+		w := httptest.NewRecorder()
+		handle(w, req)
+		require.Equal(t, 503, w.Code)
+	}
+}
+
+func TestIds_Pagination(t *testing.T) {
+	fiMap := map[string]financialInstrument{"a": {}, "b": {}, "c": {}, "d": {}, "e": {}}
+	fi := serving(&fiServiceImpl{}, "2017-08-10", fiMap)
+	h := httpHandler{fiService: fi}
+
+	var testCases = []struct {
+		query    string
+		status   int
+		expected string
+		link     string
+	}{
+		{
+			query:    "limit=2",
+			status:   200,
+			expected: `{"id":"a"}` + "\n" + `{"id":"b"}` + "\n",
+			link:     `</__ids?after=b&limit=2>; rel="next"`,
+		},
+		{
+			query:    "after=b&limit=2",
+			status:   200,
+			expected: `{"id":"c"}` + "\n" + `{"id":"d"}` + "\n",
+			link:     `</__ids?after=d&limit=2>; rel="next"`,
+		},
+		{
+			query:    "after=d&limit=2",
+			status:   200,
+			expected: `{"id":"e"}` + "\n",
+			link:     "",
+		},
+		{
+			// the cursor does not have to be an existing id
+			query:    "after=bb",
+			status:   200,
+			expected: `{"id":"c"}` + "\n" + `{"id":"d"}` + "\n" + `{"id":"e"}` + "\n",
+			link:     "",
+		},
+		{
+			query:    "after=e",
+			status:   200,
+			expected: "",
+			link:     "",
+		},
+		{
+			query:  "limit=0",
+			status: 400,
+		},
+		{
+			query:  "limit=ten",
+			status: 400,
+		},
+	}
+
+	for _, tc := range testCases {
+		req, err := http.NewRequest("GET", "/__ids?"+tc.query, nil)
+		if err != nil {
+			t.Fatalf("Failure in setting up the test request: [%v]", err)
+		}
+		w := httptest.NewRecorder()
+		h.IDs(w, req)
+
+		require.Equal(t, tc.status, w.Code, "Wrong HTTP response status code for [%s].", tc.query)
+		if tc.status != 200 {
+			continue
+		}
+		require.Equal(t, tc.expected, w.Body.String(), "Wrong ids for [%s].", tc.query)
+		require.Equal(t, tc.link, w.Header().Get("Link"), "Wrong next link for [%s].", tc.query)
+	}
+}
+
+func TestGetFinancialInstruments_Pagination(t *testing.T) {
+	baseUrl := "fiAppURL/"
+	fiMap := map[string]financialInstrument{"a": {}, "b": {}, "c": {}}
+	fi := serving(&fiServiceImpl{}, "2017-08-10", fiMap)
+	h := httpHandler{fiService: fi, baseUrl: baseUrl}
+
+	req, err := http.NewRequest("GET", "/transformers/financial-instruments?after=a&limit=1", nil)
+	if err != nil {
+		t.Fatalf("Failure in setting up the test request: [%v]", err)
+	}
+	w := httptest.NewRecorder()
+	h.getFinancialInstruments(w, req)
+
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, `[{"apiUrl":"`+baseUrl+`b"}]`+"\n", w.Body.String())
+	require.Equal(t, `</transformers/financial-instruments?after=b&limit=1>; rel="next"`, w.Header().Get("Link"))
+}
+
+func TestFIGIConflicts_ConflictsOfTheLoadedDatasetAreReturned(t *testing.T) {
+	s := &fiServiceImpl{}
+	s.swap(newDataset("2017-08-10", time.Now(), transformResult{
+		financialInstruments: map[string]financialInstrument{},
+		figiConflicts: []figiConflict{
+			{
+				Reason:    figiSharedBySecurities,
+				Kept:      conflictRecord{FactsetID: "JBP7Z7-S", FIGI: "BBG000JPVHS1"},
+				Discarded: []conflictRecord{{FactsetID: "JBP7Z8-S", FIGI: "BBG000JPVHS1"}},
+			},
+		},
+	}, datasetChanges{}))
+	h := httpHandler{fiService: s}
+
+	req, err := http.NewRequest("GET", "http://fiTransformer/__figi-conflicts", nil)
+	if err != nil {
+		t.Fatalf("Failure in setting up the test request: [%v]", err)
+	}
+	w := httptest.NewRecorder()
+
+	h.FIGIConflicts(w, req)
+
+	require.Equal(t, 200, w.Code)
+	expected := `[{"reason":"figiSharedBySecurities","kept":{"factsetIdentifier":"JBP7Z7-S","figiCode":"BBG000JPVHS1"},"discarded":[{"factsetIdentifier":"JBP7Z8-S","figiCode":"BBG000JPVHS1"}]}]` + "\n"
+	require.Equal(t, expected, w.Body.String())
+}
+
+func TestTransformReport(t *testing.T) {
+	report := transformReport{
+		ResourcesFolder:      "2017-08-10",
+		Stages:               []*stageStats{{Stage: "figiCodes", File: secToFIGIs, RowsRead: 3, RowsSkipped: 1, SkipReasons: map[string]int{skipUnknownListing: 1}}},
+		FilteredAsNonPublic:  1,
+		FinancialInstruments: 2,
+	}
+	var tests = []struct {
+		name         string
+		fis          map[string]financialInstrument
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "not initialised",
+			expectedCode: 503,
+		},
+		{
+			name:         "report of the loaded dataset",
+			fis:          map[string]financialInstrument{},
+			expectedCode: 200,
+			expectedBody: `{"resourcesFolder":"2017-08-10","started":"0001-01-01T00:00:00Z","finished":"0001-01-01T00:00:00Z","stages":[{"stage":"figiCodes","file":"sym_bbg","rowsRead":3,"rowsSkipped":1,"skipReasons":{"unknownListing":1}}],"filteredAsNonPublic":1,"withoutFigi":0,"financialInstruments":2}` + "\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &fiServiceImpl{}
+			if tc.fis != nil {
+				s.swap(newDataset("2017-08-10", time.Now(), transformResult{financialInstruments: tc.fis, report: report}, datasetChanges{}))
+			}
+			h := httpHandler{fiService: s}
+
+			req, err := http.NewRequest("GET", "http://fiTransformer/__transform-report", nil)
+			if err != nil {
+				t.Fatalf("Failure in setting up the test request: [%v]", err)
+			}
+			w := httptest.NewRecorder()
+
+			h.TransformReport(w, req)
+
+			require.Equal(t, tc.expectedCode, w.Code)
+			require.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestChanges_NoReloadSinceStartUp_EmptyListsAreReturned(t *testing.T) {
+	h := httpHandler{fiService: serving(&fiServiceImpl{}, "2017-08-10", map[string]financialInstrument{})}
+
+	req, err := http.NewRequest("GET", "http://fiTransformer/__changes", nil)
+	if err != nil {
+		t.Fatalf("Failure in setting up the test request: [%v]", err)
+	}
+	w := httptest.NewRecorder()
+
+	h.Changes(w, req)
+
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, `{"fromFolder":"","toFolder":"","computed":"0001-01-01T00:00:00Z","added":[],"removed":[],"modified":[]}`+"\n", w.Body.String())
+}
@@ -1,22 +1,35 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"github.com/Financial-Times/go-fthealth/v1a"
 	"net/http"
+	"time"
 )
 
 func (h *httpHandler) amazonS3Healthcheck() v1a.Check {
 	return v1a.Check{
 		BusinessImpact:   "Unable to read the latest dataset from S3",
 		Name:             "Check connectivity to Amazon S3",
-		PanicGuide:       "TODO",
+		PanicGuide:       "Check that the bucket in BUCKET_NAME exists in the region of S3_DOMAIN and that the AWS credentials of the service can list and read it, e.g. with `aws s3 ls s3://$BUCKET_NAME/weekly`. Until S3 is reachable again the service keeps serving the dataset it has already loaded, but it cannot pick up a new week.",
 		Severity:         1,
 		TechnicalSummary: "Cannot connect to Amazon S3 bucket to read the latest Factset dataset",
 		Checker:          h.checkConnectivityToS3,
 	}
 }
 
+func (h *httpHandler) datasetFreshnessHealthcheck() v1a.Check {
+	return v1a.Check{
+		BusinessImpact:   "Financial instruments served to UPP may be out of date",
+		Name:             "Check freshness of the loaded dataset",
+		PanicGuide:       "Look at /transformers/financial-instruments/__status: `resourcesFolder` is the weekly folder being served and `lastError` the reason the last load failed. If the weekly folder is old, check that the Factset Reader uploaded a new zip and updated the `weekly` index file in the bucket. If the last load failed, fix the cause (e.g. S3 access, a malformed zip) and reload with POST /transformers/financial-instruments/__reload.",
+		Severity:         2,
+		TechnicalSummary: "The loaded weekly folder is older than the configured maximum age, or the last attempt to load a dataset failed",
+		Checker:          h.checkDatasetFreshness,
+	}
+}
+
 func (h *httpHandler) checkConnectivityToS3() (string, error) {
 	err := h.fiService.checkConnectivity()
 	if err != nil {
@@ -25,7 +38,45 @@ func (h *httpHandler) checkConnectivityToS3() (string, error) {
 	return "", nil
 }
 
+// checkDatasetFreshness fails if no dataset has been loaded, if the last load attempt failed, or if the loaded
+// folder, or its latest daily folder, is older than maxDatasetAge. A pinned folder is old on purpose, so its age is not checked.
+func (h *httpHandler) checkDatasetFreshness() (string, error) {
+	status := h.fiService.Status()
+	if status.ResourcesFolder == "" {
+		err := errors.New("no dataset has been loaded yet")
+		if status.LastError != "" {
+			err = fmt.Errorf("no dataset has been loaded yet, last error: %s", status.LastError)
+		}
+		return "Healthcheck: " + err.Error(), err
+	}
+	if status.LastFailure.After(status.LastSuccess) {
+		err := fmt.Errorf("last load attempt at %v failed: %s", status.LastFailure.Format(time.RFC3339), status.LastError)
+		return "Healthcheck: " + err.Error(), err
+	}
+	if status.PinnedFolder != "" || h.maxDatasetAge <= 0 {
+		return fmt.Sprintf("Resources folder [%s] is loaded", status.ResourcesFolder), nil
+	}
+	latest := status.ResourcesFolder
+	if n := len(status.DailyFolders); n > 0 {
+		latest = status.DailyFolders[n-1]
+	}
+	folderDate, err := time.Parse(dateFormat, latest)
+	if err != nil {
+		return "Healthcheck: Invalid resources folder " + latest, err
+	}
+	if age := time.Since(folderDate); age > h.maxDatasetAge {
+		err := fmt.Errorf("resources folder %s is %v old, more than the maximum of %v", latest, age.Truncate(time.Hour), h.maxDatasetAge)
+		return "Healthcheck: " + err.Error(), err
+	}
+	return fmt.Sprintf("Resources folder [%s] is loaded", status.ResourcesFolder), nil
+}
+
+// goodToGo reports the service ready only once a dataset has been loaded and S3 can be reached.
 func (h *httpHandler) goodToGo(w http.ResponseWriter, r *http.Request) {
+	if !h.fiService.IsInitialised() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
 	if _, err := h.checkConnectivityToS3(); err != nil {
 		errorLogger.Println(err)
 		w.WriteHeader(http.StatusServiceUnavailable)
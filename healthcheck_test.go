@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoodToGo(t *testing.T) {
+	var tests = []struct {
+		name         string
+		fis          map[string]financialInstrument
+		s3Err        error
+		expectedCode int
+	}{
+		{
+			name:         "not initialised",
+			expectedCode: 503,
+		},
+		{
+			name:         "initialised but S3 unreachable",
+			fis:          map[string]financialInstrument{},
+			s3Err:        errors.New("connection refused"),
+			expectedCode: 503,
+		},
+		{
+			name:         "initialised and S3 reachable",
+			fis:          map[string]financialInstrument{},
+			expectedCode: 200,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := serving(&fiServiceImpl{
+				fit: &transformerMock{
+					mockCheckConnectivityToS3: func() error {
+						return tc.s3Err
+					},
+				},
+			}, "2017-08-10", tc.fis)
+			h := httpHandler{fiService: s}
+			w := httptest.NewRecorder()
+
+			h.goodToGo(w, httptest.NewRequest("GET", "/__gtg", nil))
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+		})
+	}
+}
+
+func TestCheckDatasetFreshness(t *testing.T) {
+	now := time.Now()
+	recentFolder := now.AddDate(0, 0, -3).Format(dateFormat)
+	oldFolder := now.AddDate(0, 0, -30).Format(dateFormat)
+
+	var tests = []struct {
+		name    string
+		status  loadStatus
+		pinned  string
+		healthy bool
+	}{
+		{
+			name:    "nothing loaded",
+			status:  loadStatus{LastFailure: now, LastError: "broken zip"},
+			healthy: false,
+		},
+		{
+			name:    "recent folder loaded",
+			status:  loadStatus{ResourcesFolder: recentFolder, LastSuccess: now},
+			healthy: true,
+		},
+		{
+			name:    "old folder loaded",
+			status:  loadStatus{ResourcesFolder: oldFolder, LastSuccess: now},
+			healthy: false,
+		},
+		{
+			name:    "old folder with a recent daily folder loaded",
+			status:  loadStatus{ResourcesFolder: oldFolder, DailyFolders: []string{recentFolder}, LastSuccess: now},
+			healthy: true,
+		},
+		{
+			name:    "old folder pinned",
+			status:  loadStatus{ResourcesFolder: oldFolder, LastSuccess: now},
+			pinned:  oldFolder,
+			healthy: true,
+		},
+		{
+			name:    "last reload failed",
+			status:  loadStatus{ResourcesFolder: recentFolder, LastSuccess: now.Add(-time.Hour), LastFailure: now, LastError: "broken zip"},
+			healthy: false,
+		},
+		{
+			name:    "reload succeeded after a failure",
+			status:  loadStatus{ResourcesFolder: recentFolder, LastSuccess: now, LastFailure: now.Add(-time.Hour), LastError: "broken zip"},
+			healthy: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := httpHandler{
+				fiService:     &fiServiceImpl{status: tc.status, pinnedFolder: tc.pinned},
+				maxDatasetAge: 14 * 24 * time.Hour,
+			}
+
+			msg, err := h.checkDatasetFreshness()
+
+			if tc.healthy {
+				assert.NoError(t, err, msg)
+			} else {
+				assert.Error(t, err, msg)
+			}
+		})
+	}
+}
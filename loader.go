@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/minio/minio-go"
@@ -14,39 +17,60 @@ import (
 )
 
 const (
+	weeklyIndexName  = "weekly"
 	weeklyObjectName = "/weekly.zip"
 	weeklyDir        = "weekly"
+	dailyObjectName  = "/daily.zip"
+	dailyDir         = "daily"
 	dateFormat       = "2006-01-02"
 	fileExtension    = ".txt"
 )
 
+var errResourceNotFound = errors.New("resource not found")
+
+// resourceBundle gives access to the files of a zip. It must be closed once its files have been read.
 type resourceBundle interface {
 	get(name string) (io.ReadCloser, error)
+	io.Closer
 }
 
 type rb struct {
-	z *zip.Reader
+	z      *zip.Reader
+	dir    string
+	source io.Closer
 }
 
-func newResourceBundle(z *zip.Reader) resourceBundle {
-	return &rb{z: z}
+// newResourceBundle reads the files of the given directory of a zip. The source the zip is read from, if any,
+// is closed with the bundle.
+func newResourceBundle(z *zip.Reader, dir string, source io.Closer) resourceBundle {
+	return &rb{z: z, dir: dir, source: source}
 }
 
 func (r *rb) get(name string) (io.ReadCloser, error) {
-	name = filepath.Join(weeklyDir, name+fileExtension)
+	name = filepath.Join(r.dir, name+fileExtension)
 	log.Infof("Looking for file[%v]", name)
 	for _, zf := range r.z.File {
 		if zf.Name == name {
 			return zf.Open()
 		}
 	}
-	return nil, errors.New(fmt.Sprintf("Can't find file [%v]", name))
+	return nil, errors.Wrap(errResourceNotFound, fmt.Sprintf("Can't find file [%v]", name))
+}
+
+func (r *rb) Close() error {
+	if r.source == nil {
+		return nil
+	}
+	return r.source.Close()
 }
 
 type loader interface {
 	FindLatestResourcesFolder() (string, error)
+	ListResourcesFolders() ([]string, error)
+	ListFolders() (weeklies []string, dailies []string, err error)
 	BucketExists() (bool, error)
 	GetResourceBundle(pathPrefix string) (resourceBundle, error)
+	GetDailyBundle(pathPrefix string) (resourceBundle, error)
 }
 
 type s3Loader struct {
@@ -63,7 +87,14 @@ func news3Loader(c s3Config) (s3Loader, error) {
 }
 
 func (s3Loader *s3Loader) GetResourceBundle(pathPrefix string) (resourceBundle, error) {
-	ob := pathPrefix + weeklyObjectName
+	return s3Loader.getBundle(pathPrefix+weeklyObjectName, weeklyDir)
+}
+
+func (s3Loader *s3Loader) GetDailyBundle(pathPrefix string) (resourceBundle, error) {
+	return s3Loader.getBundle(pathPrefix+dailyObjectName, dailyDir)
+}
+
+func (s3Loader *s3Loader) getBundle(ob string, dir string) (resourceBundle, error) {
 	log.Infof("bucket=[%v],objectName=[%v]", s3Loader.config.bucket, ob)
 	obj, err := s3Loader.client.GetObject(s3Loader.config.bucket, ob)
 	if err != nil {
@@ -82,7 +113,7 @@ func (s3Loader *s3Loader) GetResourceBundle(pathPrefix string) (resourceBundle,
 		log.Errorf("Error creating zip reader for object[%v], %v", ob, err.Error())
 		return nil, err
 	}
-	return newResourceBundle(z), nil
+	return newResourceBundle(z, dir, obj), nil
 }
 
 func (s3Loader *s3Loader) FindLatestResourcesFolder() (string, error) {
@@ -91,7 +122,7 @@ func (s3Loader *s3Loader) FindLatestResourcesFolder() (string, error) {
 		return "", errors.New("S3 bucket not initialised. Please call news3Loader(c s3Config) function first")
 	}
 
-	obj, err := s3Client.GetObject(s3Loader.config.bucket, "weekly")
+	obj, err := s3Client.GetObject(s3Loader.config.bucket, weeklyIndexName)
 	if err != nil {
 		log.Errorf("Error getting weekly index file: %s", err)
 		return "", err
@@ -106,6 +137,146 @@ func (s3Loader *s3Loader) FindLatestResourcesFolder() (string, error) {
 	return folder, nil
 }
 
+// ListResourcesFolders returns the sorted names of the dated folders that contain a weekly zip.
+func (s3Loader *s3Loader) ListResourcesFolders() ([]string, error) {
+	weeklies, _, err := s3Loader.ListFolders()
+	return weeklies, err
+}
+
+// ListFolders returns the sorted names of the dated folders that contain a weekly zip, and of those that contain
+// a daily zip, from a single listing of the bucket.
+func (s3Loader *s3Loader) ListFolders() ([]string, []string, error) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	weeklies, dailies := []string{}, []string{}
+	for obj := range s3Loader.client.ListObjects(s3Loader.config.bucket, "", true, doneCh) {
+		if obj.Err != nil {
+			log.Errorf("Error listing bucket[%v], %v", s3Loader.config.bucket, obj.Err.Error())
+			return nil, nil, obj.Err
+		}
+		if folder := resourcesFolderOf(obj.Key); folder != "" {
+			weeklies = append(weeklies, folder)
+		} else if folder := dailyFolderOf(obj.Key); folder != "" {
+			dailies = append(dailies, folder)
+		}
+	}
+	sort.Strings(weeklies)
+	sort.Strings(dailies)
+	return weeklies, dailies, nil
+}
+
 func (s3Loader *s3Loader) BucketExists() (bool, error) {
 	return s3Loader.client.BucketExists(s3Loader.config.bucket)
 }
+
+// fsLoader reads the weekly index file and the dated weekly.zip and daily.zip folders from a local directory
+// laid out the same way as the S3 bucket.
+type fsLoader struct {
+	dir string
+}
+
+func newFSLoader(dir string) fsLoader {
+	return fsLoader{dir: dir}
+}
+
+func (l *fsLoader) GetResourceBundle(pathPrefix string) (resourceBundle, error) {
+	return l.getBundle(pathPrefix+weeklyObjectName, weeklyDir)
+}
+
+func (l *fsLoader) GetDailyBundle(pathPrefix string) (resourceBundle, error) {
+	return l.getBundle(pathPrefix+dailyObjectName, dailyDir)
+}
+
+func (l *fsLoader) getBundle(objectName string, dir string) (resourceBundle, error) {
+	name := filepath.Join(l.dir, objectName)
+	log.Infof("dir=[%v],fileName=[%v]", l.dir, name)
+	z, err := zip.OpenReader(name)
+	if err != nil {
+		log.Errorf("Error opening zip file[%v], %v", name, err.Error())
+		return nil, err
+	}
+	return newResourceBundle(&z.Reader, dir, z), nil
+}
+
+func (l *fsLoader) FindLatestResourcesFolder() (string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(l.dir, weeklyIndexName))
+	if err != nil {
+		log.Errorf("Error reading weekly index file: %s", err)
+		return "", err
+	}
+	folder := strings.Split(strings.TrimSpace(string(content)), "/")[0]
+	log.Infof("Found latest folder: [%s]", folder)
+	return folder, nil
+}
+
+func (l *fsLoader) ListResourcesFolders() ([]string, error) {
+	return l.listFolders(weeklyObjectName)
+}
+
+func (l *fsLoader) ListFolders() ([]string, []string, error) {
+	weeklies, err := l.listFolders(weeklyObjectName)
+	if err != nil {
+		return nil, nil, err
+	}
+	dailies, err := l.listFolders(dailyObjectName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return weeklies, dailies, nil
+}
+
+func (l *fsLoader) listFolders(objectName string) ([]string, error) {
+	entries, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		log.Errorf("Error listing dir[%v], %v", l.dir, err.Error())
+		return nil, err
+	}
+	folders := []string{}
+	for _, e := range entries {
+		if !e.IsDir() || !isValidResourcesFolder(e.Name()) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(l.dir, e.Name()+objectName)); err == nil {
+			folders = append(folders, e.Name())
+		}
+	}
+	return folders, nil
+}
+
+func (l *fsLoader) BucketExists() (bool, error) {
+	fi, err := os.Stat(l.dir)
+	if err != nil {
+		return false, err
+	}
+	if !fi.IsDir() {
+		return false, errors.Errorf("[%s] is not a directory", l.dir)
+	}
+	return true, nil
+}
+
+// resourcesFolderOf returns the dated folder of a weekly zip key like 2017-08-10/weekly.zip, or "" for any other key.
+func resourcesFolderOf(key string) string {
+	return folderOf(key, weeklyObjectName)
+}
+
+// dailyFolderOf returns the dated folder of a daily zip key like 2017-08-14/daily.zip, or "" for any other key.
+func dailyFolderOf(key string) string {
+	return folderOf(key, dailyObjectName)
+}
+
+func folderOf(key string, objectName string) string {
+	if !strings.HasSuffix(key, objectName) {
+		return ""
+	}
+	folder := strings.TrimSuffix(key, objectName)
+	if !isValidResourcesFolder(folder) {
+		return ""
+	}
+	return folder
+}
+
+func isValidResourcesFolder(folder string) bool {
+	_, err := time.Parse(dateFormat, folder)
+	return err == nil
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"archive/zip"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -40,7 +42,7 @@ func TestResourceBundle_getWorks(t *testing.T) {
 	assert.NoError(t, err)
 	z, err := zip.NewReader(tmpfile, stat.Size())
 	assert.NoError(t, err)
-	bundle := newResourceBundle(z)
+	bundle := newResourceBundle(z, weeklyDir, nil)
 
 	t.Run("Should read zip file", func(t *testing.T) {
 		g, err := bundle.get("readme")
@@ -56,3 +58,144 @@ func TestResourceBundle_getWorks(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+// writeLocalDataset zips the fixture files in testdata/weekly into <dir>/<folder>/weekly.zip
+// and points the weekly index file at it, mirroring what the Factset Reader uploads to S3.
+func writeLocalDataset(t *testing.T, dir string, folder string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, folder), 0755))
+	zf, err := os.Create(filepath.Join(dir, folder+weeklyObjectName))
+	require.NoError(t, err)
+	defer zf.Close()
+
+	fixtures, err := filepath.Glob(filepath.Join("testdata", weeklyDir, "*"+fileExtension))
+	require.NoError(t, err)
+	w := zip.NewWriter(zf)
+	for _, fixture := range fixtures {
+		body, err := ioutil.ReadFile(fixture)
+		require.NoError(t, err)
+		f, err := w.Create(filepath.Join(weeklyDir, filepath.Base(fixture)))
+		require.NoError(t, err)
+		_, err = f.Write(body)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, weeklyIndexName), []byte(folder+weeklyObjectName+"\n"), 0644))
+}
+
+// writeLocalDaily zips the given files into <dir>/<folder>/daily.zip, as uploaded by the Factset Reader for a daily delta.
+func writeLocalDaily(t *testing.T, dir string, folder string, files map[string]string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, folder), 0755))
+	zf, err := os.Create(filepath.Join(dir, folder+dailyObjectName))
+	require.NoError(t, err)
+	defer zf.Close()
+
+	w := zip.NewWriter(zf)
+	for name, body := range files {
+		f, err := w.Create(filepath.Join(dailyDir, name+fileExtension))
+		require.NoError(t, err)
+		_, err = f.Write([]byte(body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+}
+
+func TestFSLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fis_test_data")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeLocalDataset(t, dir, "2017-08-10")
+
+	l := newFSLoader(dir)
+
+	t.Run("Should find latest folder", func(t *testing.T) {
+		folder, err := l.FindLatestResourcesFolder()
+		assert.NoError(t, err)
+		assert.Equal(t, "2017-08-10", folder)
+	})
+
+	t.Run("Should read files of the weekly zip", func(t *testing.T) {
+		bundle, err := l.GetResourceBundle("2017-08-10")
+		require.NoError(t, err)
+		defer bundle.Close()
+		g, err := bundle.get(secToFIGIs)
+		require.NoError(t, err)
+		defer g.Close()
+		bs, err := ioutil.ReadAll(g)
+		assert.NoError(t, err)
+		assert.Contains(t, string(bs), "BBG000BDN0W4")
+	})
+
+	t.Run("Should close the zip file with the bundle", func(t *testing.T) {
+		bundle, err := l.GetResourceBundle("2017-08-10")
+		require.NoError(t, err)
+		require.NoError(t, bundle.Close())
+
+		g, err := bundle.get(secToFIGIs)
+		if err == nil {
+			_, err = ioutil.ReadAll(g)
+		}
+		assert.Error(t, err)
+	})
+
+	t.Run("Should error if folder does not exist", func(t *testing.T) {
+		_, err := l.GetResourceBundle("2017-08-03")
+		assert.Error(t, err)
+	})
+
+	t.Run("Should list dated folders containing a weekly zip", func(t *testing.T) {
+		writeLocalDataset(t, dir, "2017-08-03")
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "2017-08-17"), 0755))
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "tmp"), 0755))
+
+		folders, err := l.ListResourcesFolders()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2017-08-03", "2017-08-10"}, folders)
+	})
+
+	t.Run("Should list and read daily zips", func(t *testing.T) {
+		writeLocalDaily(t, dir, "2017-08-14", map[string]string{secToFIGIs: `"FSYM_ID"|"BBG_ID"|"BBG_TICKER"`})
+
+		weeklies, dailies, err := l.ListFolders()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2017-08-03", "2017-08-10"}, weeklies)
+		assert.Equal(t, []string{"2017-08-14"}, dailies)
+
+		bundle, err := l.GetDailyBundle("2017-08-14")
+		require.NoError(t, err)
+		defer bundle.Close()
+		g, err := bundle.get(secToFIGIs)
+		require.NoError(t, err)
+		g.Close()
+		_, err = bundle.get(securities)
+		assert.Equal(t, errResourceNotFound, errors.Cause(err))
+	})
+
+	t.Run("Should report whether the directory exists", func(t *testing.T) {
+		exists, err := l.BucketExists()
+		assert.NoError(t, err)
+		assert.True(t, exists)
+
+		missing := newFSLoader(filepath.Join(dir, "missing"))
+		exists, err = missing.BucketExists()
+		assert.Error(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestResourcesFolderOf(t *testing.T) {
+	var testCases = []struct {
+		key      string
+		expected string
+	}{
+		{"2017-08-10/weekly.zip", "2017-08-10"},
+		{"weekly", ""},
+		{"2017-08-10/daily.zip", ""},
+		{"latest/weekly.zip", ""},
+		{"2017-13-10/weekly.zip", ""},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, resourcesFolderOf(tc.key), "Wrong folder for key [%s]", tc.key)
+	}
+}
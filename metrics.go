@@ -0,0 +1,109 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "financial_instruments_transformer"
+
+var (
+	transformDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "transform_duration_seconds",
+		Help:      "Duration of the transformations of a resources folder, by outcome.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200},
+	}, []string{"outcome"})
+
+	rowsParsed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rows_parsed_total",
+		Help:      "Rows read from the Factset files, by parsing stage and file.",
+	}, []string{"stage", "file"})
+
+	rowsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rows_skipped_total",
+		Help:      "Rows skipped while parsing the Factset files, by parsing stage, file and reason.",
+	}, []string{"stage", "file", "reason"})
+
+	instrumentsLoaded = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "instruments_loaded",
+		Help:      "Number of financial instruments being served.",
+	})
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the HTTP requests, by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+)
+
+func init() {
+	prometheus.MustRegister(transformDuration, rowsParsed, rowsSkipped, instrumentsLoaded, httpRequests, httpRequestDuration)
+}
+
+// newDatasetAgeGauge reports the seconds since the dataset being served was last loaded successfully,
+// or +Inf while no dataset has been loaded, so that an alert on a maximum age also fires for a service that never loaded one.
+func newDatasetAgeGauge(s fiService) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "dataset_age_seconds",
+		Help:      "Seconds since the dataset being served was last loaded successfully.",
+	}, func() float64 {
+		lastSuccess := s.Status().LastSuccess
+		if lastSuccess.IsZero() {
+			return math.Inf(1)
+		}
+		return time.Since(lastSuccess).Seconds()
+	})
+}
+
+func observeTransform(duration time.Duration, result transformResult, err error) {
+	if err != nil {
+		transformDuration.WithLabelValues("failure").Observe(duration.Seconds())
+		return
+	}
+	transformDuration.WithLabelValues("success").Observe(duration.Seconds())
+	for _, stage := range result.report.Stages {
+		rowsParsed.WithLabelValues(stage.Stage, stage.File).Add(float64(stage.RowsRead))
+		for reason, count := range stage.SkipReasons {
+			rowsSkipped.WithLabelValues(stage.Stage, stage.File, reason).Add(float64(count))
+		}
+	}
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// withMetrics counts and times the requests to the given route. The route is the path template
+// rather than the request path, so that the number of series does not grow with the IDs being read.
+func withMetrics(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(sr, r)
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(sr.status)).Inc()
+		httpRequestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	}
+}
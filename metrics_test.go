@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithMetrics_RequestsAreCountedByRouteTemplateAndStatus(t *testing.T) {
+	route := "/transformers/financial-instruments/{id}"
+	notFound := httpRequests.WithLabelValues(route, "GET", "404")
+	ok := httpRequests.WithLabelValues(route, "GET", "200")
+	notFoundBefore, okBefore := testutil.ToFloat64(notFound), testutil.ToFloat64(ok)
+
+	h := withMetrics(route, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/transformers/financial-instruments/unknown" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+	for _, id := range []string{"unknown", "404c8329-3f8e-348e-ba32-cf3eb2c1ffed", "8404dbec-2423-322d-9afa-f92e553e53b6"} {
+		req := httptest.NewRequest("GET", "/transformers/financial-instruments/"+id, nil)
+		h(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(notFound)-notFoundBefore)
+	assert.Equal(t, 2.0, testutil.ToFloat64(ok)-okBefore)
+}
+
+func TestObserveTransform_RowsAreCountedPerStage(t *testing.T) {
+	parsed := rowsParsed.WithLabelValues("figiCodes", secToFIGIs)
+	skipped := rowsSkipped.WithLabelValues("figiCodes", secToFIGIs, skipUnknownListing)
+	parsedBefore, skippedBefore := testutil.ToFloat64(parsed), testutil.ToFloat64(skipped)
+
+	result := transformResult{report: transformReport{
+		Stages: []*stageStats{{Stage: "figiCodes", File: secToFIGIs, RowsRead: 3, RowsSkipped: 1, SkipReasons: map[string]int{skipUnknownListing: 1}}},
+	}}
+	observeTransform(time.Second, result, nil)
+	observeTransform(time.Second, transformResult{}, errors.New("broken zip"))
+
+	assert.Equal(t, 3.0, testutil.ToFloat64(parsed)-parsedBefore)
+	assert.Equal(t, 1.0, testutil.ToFloat64(skipped)-skippedBefore)
+}
+
+func TestDatasetAgeGauge(t *testing.T) {
+	s := &fiServiceImpl{}
+	gauge := newDatasetAgeGauge(s)
+	assert.True(t, math.IsInf(testutil.ToFloat64(gauge), 1), "age must be infinite before the first load")
+
+	s.status.LastSuccess = time.Now().Add(-time.Hour)
+	age := testutil.ToFloat64(gauge)
+	assert.True(t, age >= 3600 && age < 3660, "unexpected age [%v]", age)
+}
@@ -1,10 +1,31 @@
 package main
 
 type financialInstrument struct {
-	figiCode     string
-	securityID   string
-	orgID        string //UPP UUID
-	securityName string
+	figiCode      string
+	securityID    string
+	orgID         string //UPP UUID
+	securityName  string
+	ticker        string // Bloomberg ticker of the primary listing
+	exchange      string // Factset exchange code of the primary listing
+	currency      string // trading currency of the primary listing
+	issuerCountry string // ISO country of the issuer
+	listings      []listing
+}
+
+// listing is where a security trades: the primary listing of one of its regional securities.
+type listing struct {
+	listingID string
+	figiCode  string
+	ticker    string
+	exchange  string
+	currency  string
+	primary   bool // listing of the primary regional security
+}
+
+// listingCodes are the Bloomberg codes of a listing.
+type listingCodes struct {
+	figiCode string
+	ticker   string
 }
 
 // raw financial instrument model as it comes from Factset
@@ -14,6 +35,28 @@ type rawFinancialInstrument struct {
 	fiType           string
 	securityName     string
 	primaryListingID string
+	exchange         string
+	currency         string
+	issuerCountry    string
+	listings         []listing
+}
+
+const (
+	figiSharedBySecurities   = "figiSharedBySecurities"
+	securityWithSeveralFIGIs = "securityWithSeveralFIGIs"
+)
+
+// figiConflict records the records competing for the same FIGI or security, which one was kept and which ones were discarded.
+// When several securities share a FIGI, the lowest Factset security ID keeps it; when a security has several FIGIs, the lowest FIGI is kept.
+type figiConflict struct {
+	Reason    string           `json:"reason"`
+	Kept      conflictRecord   `json:"kept"`
+	Discarded []conflictRecord `json:"discarded"`
+}
+
+type conflictRecord struct {
+	FactsetID string `json:"factsetIdentifier"`
+	FIGI      string `json:"figiCode"`
 }
 
 type s3Config struct {
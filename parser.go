@@ -1,78 +1,124 @@
 package main
 
 import (
-	"bufio"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 )
 
-const publicEntity = "PUB"
-
 type fiParser interface {
-	parseFIs(secReader io.Reader, secOrgReader io.Reader) (map[string]rawFinancialInstrument, error)
-	parseListings(r io.Reader, fis map[string]rawFinancialInstrument) map[string]string
-	parseFIGICodes(r io.Reader, listings map[string]string) (map[string]string, error)
-	parseEntityFunc() func(r io.ReadCloser) map[string]bool
+	parseFIs(secReader io.Reader, secOrgReader io.Reader, report *transformReport) (map[string]rawFinancialInstrument, error)
+	parseListings(r io.Reader, fis map[string]rawFinancialInstrument, report *transformReport) (map[string]string, error)
+	parseFIGICodes(r io.Reader, listings map[string]string, report *transformReport) (map[string]string, []figiConflict, error)
+	parseListingCodes(r io.Reader, fis map[string]rawFinancialInstrument, report *transformReport) (map[string]listingCodes, error)
+	parseEntityFunc() func(r io.ReadCloser, report *transformReport) (map[string]string, error)
 }
 
-type fiParserImpl struct{}
+type fiParserImpl struct {
+	rules selectionRules
+}
 
-func (fip *fiParserImpl) parseFIs(secReader io.Reader, secOrgReader io.Reader) (map[string]rawFinancialInstrument, error) {
+func newFIParser(rules selectionRules) *fiParserImpl {
+	return &fiParserImpl{rules: rules}
+}
+
+func (fip *fiParserImpl) parseFIs(secReader io.Reader, secOrgReader io.Reader, report *transformReport) (map[string]rawFinancialInstrument, error) {
 	infoLogger.Println("Starting security parsing.")
+	stats := report.stage("securities", securities)
 	rawFIs := make(map[string]rawFinancialInstrument)
-	scanner := bufio.NewScanner(secReader)
-	scanner.Scan() // skip the first line (contains the column names)
-	for scanner.Scan() {
-		record := strings.Split(strings.Replace(scanner.Text(), `"`, ``, -1), "|")
-		if len(record) < 14 {
-			infoLogger.Println("Skip raw fi:", record)
+	rows := newPipeReader(secReader, securities)
+	cols, err := readColumns(rows, colFSYMID, colProperName, colPrimaryEquityID, colPrimaryListingID,
+		colActiveFlag, colSecurityType, colUniverseType)
+	if err != nil {
+		return nil, err
+	}
+	for rows.next() {
+		stats.read()
+		if line, malformed := rows.malformed(); malformed {
+			stats.skipMalformed(line)
 			continue
 		}
-		securityID := record[0]
-		universeType := record[13]
-		activeFlag, err := strconv.Atoi(record[5])
-		if err != nil {
-			errorLogger.Println(err)
+		record := rows.fields()
+		if !cols.complete(record) {
+			infoLogger.Println("Skip raw fi:", record)
+			stats.skip(skipIncompleteRecord)
 			continue
 		}
+		securityID := cols.get(record, colFSYMID)
+		universeType := cols.get(record, colUniverseType)
+		activeFlag := 1
+		if fip.rules.ActiveOnly {
+			activeFlag, err = strconv.Atoi(cols.get(record, colActiveFlag))
+			if err != nil {
+				errorLogger.Println(err)
+				stats.skip(skipInvalidActiveFlag)
+				continue
+			}
+		}
 
-		primaryEquityID := record[3]
-		primaryListingID := record[4]
-		securityType := record[6]
-
-		if universeType == "EQ" &&
-			strings.HasSuffix(securityID, "-S") &&
-			activeFlag == 1 &&
-			primaryEquityID == securityID &&
-			securityType == "SHARE" &&
-			primaryListingID != "" {
+		primaryEquityID := cols.get(record, colPrimaryEquityID)
+		primaryListingID := cols.get(record, colPrimaryListingID)
+		securityType := cols.get(record, colSecurityType)
 
+		switch {
+		case !stats.rule(ruleUniverseType, contains(fip.rules.UniverseTypes, universeType)):
+			stats.skip(skipUniverseTypeRejected)
+		case !stats.rule(ruleSecurityIDSuffix, fip.rules.hasSecurityIDSuffix(securityID)):
+			stats.skip(skipSecurityIDSuffixRejected)
+		case !stats.rule(ruleActive, !fip.rules.ActiveOnly || activeFlag == 1):
+			stats.skip(skipInactive)
+		case !stats.rule(rulePrimaryEquity, !fip.rules.PrimaryEquityOnly || primaryEquityID == securityID):
+			stats.skip(skipNotPrimaryEquity)
+		case !stats.rule(ruleSecurityType, contains(fip.rules.SecurityTypes, securityType)):
+			stats.skip(skipSecurityTypeRejected)
+		case primaryListingID == "":
+			stats.skip(skipNoPrimaryListing)
+		default:
 			equity := rawFinancialInstrument{
 				securityID:       securityID,
 				fiType:           universeType,
-				securityName:     record[2],
-				primaryListingID: record[4],
+				securityName:     cols.get(record, colProperName),
+				primaryListingID: primaryListingID,
 			}
 			rawFIs[securityID] = equity
 		}
 	}
+	if err := rows.err(); err != nil {
+		return nil, err
+	}
 
 	infoLogger.Println("Starting sec-org mapping parsing.")
-	scanner = bufio.NewScanner(secOrgReader)
-	scanner.Scan() // skip the first line (contains the column names)
-	for scanner.Scan() {
-		record := strings.Split(strings.Replace(scanner.Text(), `"`, ``, -1), "|")
-		if len(record) < 2 {
+	stats = report.stage("securityEntityMap", securityEntityMap)
+	rows = newPipeReader(secOrgReader, securityEntityMap)
+	cols, err = readColumns(rows, colFSYMID, colFactsetEntityID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.next() {
+		stats.read()
+		if line, malformed := rows.malformed(); malformed {
+			stats.skipMalformed(line)
+			continue
+		}
+		record := rows.fields()
+		if !cols.complete(record) {
 			infoLogger.Println("Skip sec-org mapping:", record)
+			stats.skip(skipIncompleteRecord)
 			continue
 		}
-		securityID := record[0]
-		orgID := record[1]
-		if fi, ok := rawFIs[securityID]; ok {
-			fi.orgID = orgID
-			rawFIs[securityID] = fi
+		securityID := cols.get(record, colFSYMID)
+		orgID := cols.get(record, colFactsetEntityID)
+		fi, ok := rawFIs[securityID]
+		if !ok {
+			stats.skip(skipUnknownSecurity)
+			continue
 		}
+		fi.orgID = orgID
+		rawFIs[securityID] = fi
+	}
+	if err := rows.err(); err != nil {
+		return nil, err
 	}
 
 	infoLogger.Printf("Fetched securities. Nr of records: [%d]", len(rawFIs))
@@ -80,76 +126,244 @@ func (fip *fiParserImpl) parseFIs(secReader io.Reader, secOrgReader io.Reader) (
 	return rawFIs, nil
 }
 
-func (fip *fiParserImpl) parseFIGICodes(r io.Reader, listings map[string]string) (map[string]string, error) {
+// parseFIGICodes maps the FIGI codes of the listings to their securities.
+// A FIGI found on the listings of several securities is given to the lowest security ID, and the conflict is reported.
+func (fip *fiParserImpl) parseFIGICodes(r io.Reader, listings map[string]string, report *transformReport) (map[string]string, []figiConflict, error) {
 	infoLogger.Println("Starting FIGI code parsing.")
-	figiCodes := make(map[string]string)
-	scanner := bufio.NewScanner(r)
-	scanner.Scan() // skip first line
-	for scanner.Scan() {
-		record := strings.Split(strings.Replace(scanner.Text(), `"`, ``, -1), "|")
-		if len(record) < 2 {
+	stats := report.stage("figiCodes", secToFIGIs)
+	securityIDsByFIGI := make(map[string][]string)
+	rows := newPipeReader(r, secToFIGIs)
+	cols, err := readColumns(rows, colFSYMID, colBloombergID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for rows.next() {
+		stats.read()
+		if line, malformed := rows.malformed(); malformed {
+			stats.skipMalformed(line)
+			continue
+		}
+		record := rows.fields()
+		if !cols.complete(record) {
 			infoLogger.Println("Skip figi code:", record)
+			stats.skip(skipIncompleteRecord)
+			continue
+		}
+		securityID, ok := listings[cols.get(record, colFSYMID)]
+		if !ok {
+			stats.skip(skipUnknownListing)
+			continue
+		}
+		figi := cols.get(record, colBloombergID)
+		if !contains(securityIDsByFIGI[figi], securityID) {
+			securityIDsByFIGI[figi] = append(securityIDsByFIGI[figi], securityID)
+		}
+	}
+	if err := rows.err(); err != nil {
+		return nil, nil, err
+	}
+
+	figiCodes := make(map[string]string)
+	var conflicts []figiConflict
+	for figi, securityIDs := range securityIDsByFIGI {
+		sort.Strings(securityIDs)
+		figiCodes[figi] = securityIDs[0]
+		if len(securityIDs) > 1 {
+			conflict := figiConflict{
+				Reason: figiSharedBySecurities,
+				Kept:   conflictRecord{FactsetID: securityIDs[0], FIGI: figi},
+			}
+			for _, discarded := range securityIDs[1:] {
+				conflict.Discarded = append(conflict.Discarded, conflictRecord{FactsetID: discarded, FIGI: figi})
+			}
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	sortFIGIConflicts(conflicts)
+	infoLogger.Printf("Fetched figi codes. Nr of records: [%d]. Nr of FIGIs shared by several securities: [%d]", len(figiCodes), len(conflicts))
+
+	return figiCodes, conflicts, nil
+}
+
+// parseListingCodes maps the listings of the securities to their FIGI and Bloomberg ticker.
+func (fip *fiParserImpl) parseListingCodes(r io.Reader, fis map[string]rawFinancialInstrument, report *transformReport) (map[string]listingCodes, error) {
+	infoLogger.Println("Starting listing codes parsing.")
+	stats := report.stage("listingCodes", secToFIGIs)
+	known := make(map[string]bool)
+	for _, fi := range fis {
+		for _, l := range fi.listings {
+			known[l.listingID] = true
+		}
+	}
+	codes := make(map[string]listingCodes)
+	rows := newPipeReader(r, secToFIGIs)
+	cols, err := readColumns(rows, colFSYMID, colBloombergID, colBloombergTicker)
+	if err != nil {
+		return nil, err
+	}
+	for rows.next() {
+		stats.read()
+		if line, malformed := rows.malformed(); malformed {
+			stats.skipMalformed(line)
+			continue
+		}
+		record := rows.fields()
+		if !cols.complete(record) {
+			stats.skip(skipIncompleteRecord)
+			continue
+		}
+		listingID := cols.get(record, colFSYMID)
+		if !known[listingID] {
+			stats.skip(skipUnknownListing)
 			continue
 		}
-		if securityID, ok := listings[record[0]]; ok {
-			figiCodes[record[1]] = securityID
+		codes[listingID] = listingCodes{
+			figiCode: cols.get(record, colBloombergID),
+			ticker:   cols.get(record, colBloombergTicker),
 		}
 	}
-	infoLogger.Printf("Fetched figi codes. Nr of records: [%d]", len(figiCodes))
+	if err := rows.err(); err != nil {
+		return nil, err
+	}
+	infoLogger.Printf("Fetched listing codes. Nr of records: [%d]", len(codes))
+	return codes, nil
+}
 
-	return figiCodes, nil
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
-func (fip *fiParserImpl) parseListings(r io.Reader, fis map[string]rawFinancialInstrument) map[string]string {
+// regionalListings is a regional security of the securities file, with the listings recorded under it.
+type regionalListings struct {
+	securityID       string
+	primaryListingID string
+	listings         []listing
+}
+
+// parseListings records the listings of every regional security on the security it belongs to.
+// The primary listing of a regional security is taken from its regional row, and its other listings from their listing rows.
+// The primary listing of the primary regional security is the primary listing of the security, and is mapped to it.
+func (fip *fiParserImpl) parseListings(r io.Reader, fis map[string]rawFinancialInstrument, report *transformReport) (map[string]string, error) {
 	infoLogger.Println("Starting listings parsing.")
+	stats := report.stage("listings", securities)
 	listings := make(map[string]string)
-	scanner := bufio.NewScanner(r)
-	scanner.Scan() // skip first line
-	for scanner.Scan() {
-		record := strings.Split(strings.Replace(scanner.Text(), `"`, ``, -1), "|")
-		if len(record) < 5 {
+	rows := newPipeReader(r, securities)
+	cols, err := readColumns(rows, colFSYMID, colCurrency, colPrimaryListingID, colListingExchange, colRegionalID, colSecurityID)
+	if err != nil {
+		return nil, err
+	}
+	regionals := make(map[string]*regionalListings)
+	var regionalIDs []string
+	var secondaries []listing
+	var secondaryRegionalIDs []string
+	for rows.next() {
+		stats.read()
+		if line, malformed := rows.malformed(); malformed {
+			stats.skipMalformed(line)
+			continue
+		}
+		record := rows.fields()
+		if !cols.complete(record) {
 			infoLogger.Println("Skip listing:", record)
+			stats.skip(skipIncompleteRecord)
 			continue
 		}
-		securityID := record[0]
-		primaryEquityID := record[3]
-
-		if strings.HasSuffix(securityID, "-R") && primaryEquityID != "" {
-			primaryListingID := record[4]
-			if primaryListingID == "" {
+		fsymID := cols.get(record, colFSYMID)
+		l := listing{
+			listingID: fsymID,
+			exchange:  cols.get(record, colListingExchange),
+			currency:  cols.get(record, colCurrency),
+		}
+		switch {
+		case strings.HasSuffix(fsymID, "-L"):
+			secondaries = append(secondaries, l)
+			secondaryRegionalIDs = append(secondaryRegionalIDs, cols.get(record, colRegionalID))
+		case !strings.HasSuffix(fsymID, "-R"):
+			stats.skip(skipNotRegional)
+		case cols.get(record, colPrimaryListingID) == "":
+			stats.skip(skipNoPrimaryListing)
+		default:
+			securityID := cols.get(record, colSecurityID)
+			if _, ok := fis[securityID]; !ok {
+				stats.skip(skipUnknownSecurity)
 				continue
 			}
+			l.listingID = cols.get(record, colPrimaryListingID)
+			regionals[fsymID] = &regionalListings{securityID: securityID, primaryListingID: l.listingID, listings: []listing{l}}
+			regionalIDs = append(regionalIDs, fsymID)
+		}
+	}
+	if err := rows.err(); err != nil {
+		return nil, err
+	}
 
-			rawFi, ok := fis[primaryEquityID]
-			if !ok || rawFi.primaryListingID != securityID {
-				continue
+	for i, l := range secondaries {
+		regional, ok := regionals[secondaryRegionalIDs[i]]
+		if !ok {
+			stats.skip(skipUnknownSecurity)
+			continue
+		}
+		if l.listingID != regional.primaryListingID {
+			regional.listings = append(regional.listings, l)
+		}
+	}
+	for _, regionalID := range regionalIDs {
+		regional := regionals[regionalID]
+		rawFi := fis[regional.securityID]
+		for _, l := range regional.listings {
+			l.primary = rawFi.primaryListingID == regionalID && l.listingID == regional.primaryListingID
+			rawFi.listings = append(rawFi.listings, l)
+			if l.primary {
+				listings[l.listingID] = regional.securityID
+				rawFi.currency = l.currency
+				rawFi.exchange = l.exchange
 			}
-			listings[primaryListingID] = primaryEquityID
 		}
+		fis[regional.securityID] = rawFi
 	}
 	infoLogger.Printf("Fetched listings. Nr of records: [%v]", len(listings))
-	return listings
+	return listings, nil
 }
 
-func (fip *fiParserImpl) parseEntityFunc() func(r io.ReadCloser) map[string]bool {
-	return func(r io.ReadCloser) map[string]bool {
+// parseEntityFunc returns a function mapping the IDs of the entities selected by the entity type rule, the public ones
+// by default, to their ISO country.
+func (fip *fiParserImpl) parseEntityFunc() func(r io.ReadCloser, report *transformReport) (map[string]string, error) {
+	return func(r io.ReadCloser, report *transformReport) (map[string]string, error) {
 		infoLogger.Println("Starting entity parsing.")
-		entities := make(map[string]bool)
-		scanner := bufio.NewScanner(r)
-		scanner.Scan() // skip first line
-		for scanner.Scan() {
-			record := strings.Split(strings.Replace(scanner.Text(), `"`, ``, -1), "|")
-			if len(record) < 12 {
+		stats := report.stage("entities", entities)
+		publicEntities := make(map[string]string)
+		rows := newPipeReader(r, entities)
+		cols, err := readColumns(rows, colFactsetEntityID, colEntityISOCountry, colEntityType)
+		if err != nil {
+			return nil, err
+		}
+		for rows.next() {
+			stats.read()
+			if line, malformed := rows.malformed(); malformed {
+				stats.skipMalformed(line)
+				continue
+			}
+			record := rows.fields()
+			if !cols.complete(record) {
 				infoLogger.Println("Skip entity:", record)
+				stats.skip(skipIncompleteRecord)
 				continue
 			}
-			entityID := record[0]
-			entityType := record[11]
-			if entityType == publicEntity {
-				entities[entityID] = true
+			if !stats.rule(ruleEntityType, contains(fip.rules.EntityTypes, cols.get(record, colEntityType))) {
+				stats.skip(skipEntityTypeRejected)
+				continue
 			}
+			publicEntities[cols.get(record, colFactsetEntityID)] = cols.get(record, colEntityISOCountry)
+		}
+		if err := rows.err(); err != nil {
+			return nil, err
 		}
-		infoLogger.Printf("Fetched public entities. Nr of records: [%v]", len(entities))
-		return entities
+		infoLogger.Printf("Fetched public entities. Nr of records: [%v]", len(publicEntities))
+		return publicEntities, nil
 	}
 }
@@ -6,9 +6,11 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/pkg/errors"
 )
 
-var testFIParser = &fiParserImpl{}
+var testFIParser = newFIParser(defaultSelectionRules)
 
 func wrapInReadCloser(s string) io.ReadCloser {
 	return ioutil.NopCloser(strings.NewReader(s))
@@ -21,9 +23,9 @@ func TestParseFIs(t *testing.T) {
 		secEntityMap string
 		expected     map[string]rawFinancialInstrument
 	}{
-		// first line is ignored
+		// first line holds the column names
 		{
-			securities:   `"JBP7Z8-S"|""|"Industrija Precizne Mehanike AD"|"JBP7Z8-S"|"WHV8G2-R"|1|"SHARE"|""|0|0|1|"WHV8G2-R"|"JBP7Z8-S"|"EQ"`,
+			securities:   `"FSYM_ID"|"CURRENCY"|"PROPER_NAME"|"FSYM_PRIMARY_EQUITY_ID"|"FSYM_PRIMARY_LISTING_ID"|"ACTIVE_FLAG"|"FREF_SECURITY_TYPE"|"FREF_LISTING_EXCHANGE"|"LISTING_FLAG"|"REGIONAL_FLAG"|"SECURITY_FLAG"|"FSYM_REGIONAL_ID"|"FSYM_SECURITY_ID"|"UNIVERSE_TYPE"`,
 			secEntityMap: ``,
 			expected:     map[string]rawFinancialInstrument{},
 		},
@@ -46,7 +48,7 @@ func TestParseFIs(t *testing.T) {
 		{
 			securities: `"FSYM_ID"|"CURRENCY"|"PROPER_NAME"|"FSYM_PRIMARY_EQUITY_ID"|"FSYM_PRIMARY_LISTING_ID"|"ACTIVE_FLAG"|"FREF_SECURITY_TYPE"|"FREF_LISTING_EXCHANGE"|"LISTING_FLAG"|"REGIONAL_FLAG"|"SECURITY_FLAG"|"FSYM_REGIONAL_ID"|"FSYM_SECURITY_ID"|"UNIVERSE_TYPE"` + "\n" +
 				`"JBP7Z8-S"|""|"Industrija Precizne Mehanike AD"|"JBP7Z8-S"|"WHV8G2-R"|1|"SHARE"|""|0|0|1|"WHV8G2-R"|"JBP7Z8-S"|"EQ"`,
-			secEntityMap: `FSYM_ID"|"FACTSET_ENTITY_ID"` + "\n" +
+			secEntityMap: `"FSYM_ID"|"FACTSET_ENTITY_ID"` + "\n" +
 				`"JBP7Z8-S"|"092VYW-E"`,
 			expected: map[string]rawFinancialInstrument{
 				"JBP7Z8-S": rawFinancialInstrument{
@@ -104,7 +106,7 @@ func TestParseFIs(t *testing.T) {
 	}
 
 	for _, tc := range tests {
-		fis, err := testFIParser.parseFIs(wrapInReadCloser(tc.securities), wrapInReadCloser(tc.secEntityMap))
+		fis, err := testFIParser.parseFIs(wrapInReadCloser(tc.securities), wrapInReadCloser(tc.secEntityMap), nil)
 		if err != nil {
 			t.Error(err)
 		}
@@ -114,6 +116,130 @@ func TestParseFIs(t *testing.T) {
 	}
 }
 
+func TestParseFIs_ReorderedAndAddedColumns(t *testing.T) {
+	securities := `"UNIVERSE_TYPE"|"FSYM_ID"|"ISO_COUNTRY"|"ACTIVE_FLAG"|"PROPER_NAME"|"FREF_SECURITY_TYPE"|"FSYM_PRIMARY_LISTING_ID"|"FSYM_PRIMARY_EQUITY_ID"` + "\n" +
+		`"EQ"|"JBP7Z8-S"|"RS"|1|"Industrija Precizne Mehanike AD"|"SHARE"|"WHV8G2-R"|"JBP7Z8-S"`
+	secEntityMap := `"FACTSET_ENTITY_ID"|"FSYM_ID"` + "\n" +
+		`"092VYW-E"|"JBP7Z8-S"`
+
+	fis, err := testFIParser.parseFIs(wrapInReadCloser(securities), wrapInReadCloser(secEntityMap), nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]rawFinancialInstrument{
+		"JBP7Z8-S": {
+			securityID:       "JBP7Z8-S",
+			fiType:           "EQ",
+			securityName:     "Industrija Precizne Mehanike AD",
+			primaryListingID: "WHV8G2-R",
+			orgID:            "092VYW-E",
+		},
+	}
+	if !reflect.DeepEqual(fis, expected) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expected, fis)
+	}
+}
+
+func TestParseFIs_QuotedAndMalformedRecords(t *testing.T) {
+	securityRows := `"FSYM_ID"|"CURRENCY"|"PROPER_NAME"|"FSYM_PRIMARY_EQUITY_ID"|"FSYM_PRIMARY_LISTING_ID"|"ACTIVE_FLAG"|"FREF_SECURITY_TYPE"|"FREF_LISTING_EXCHANGE"|"LISTING_FLAG"|"REGIONAL_FLAG"|"SECURITY_FLAG"|"FSYM_REGIONAL_ID"|"FSYM_SECURITY_ID"|"UNIVERSE_TYPE"` + "\n" +
+		`"JBP7Z8-S"|""|"Industrija | Precizne ""IPM"" AD"|"JBP7Z8-S"|"WHV8G2-R"|1|"SHARE"|""|0|0|1|"WHV8G2-R"|"JBP7Z8-S"|"EQ"` + "\n" +
+		`"GG9B0P-S"|""|"Marks "&" Spencer Group Plc"|"GG9B0P-S"|"H73FN8-R"|1|"SHARE"|""|0|0|1|"H73FN8-R"|"GG9B0P-S"|"EQ"`
+	report := &transformReport{}
+
+	fis, err := testFIParser.parseFIs(wrapInReadCloser(securityRows), wrapInReadCloser(``), report)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]rawFinancialInstrument{
+		"JBP7Z8-S": {
+			securityID:       "JBP7Z8-S",
+			fiType:           "EQ",
+			securityName:     `Industrija | Precizne "IPM" AD`,
+			primaryListingID: "WHV8G2-R",
+		},
+	}
+	if !reflect.DeepEqual(fis, expected) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expected, fis)
+	}
+	expectedStats := &stageStats{
+		Stage:          "securities",
+		File:           securities,
+		RowsRead:       2,
+		RowsSkipped:    1,
+		SkipReasons:    map[string]int{skipMalformedRecord: 1},
+		MalformedLines: []int{3},
+		Rules: []*ruleStats{
+			{Rule: ruleUniverseType, Accepted: 1},
+			{Rule: ruleSecurityIDSuffix, Accepted: 1},
+			{Rule: ruleActive, Accepted: 1},
+			{Rule: rulePrimaryEquity, Accepted: 1},
+			{Rule: ruleSecurityType, Accepted: 1},
+		},
+	}
+	if !reflect.DeepEqual(report.Stages[0], expectedStats) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expectedStats, report.Stages[0])
+	}
+}
+
+func TestParsers_MissingColumn_Fail(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		parse func() error
+	}{
+		{
+			name: "securities",
+			parse: func() error {
+				_, err := testFIParser.parseFIs(wrapInReadCloser(`"FSYM_ID"|"CURRENCY"|"PROPER_NAME"`), wrapInReadCloser(``), nil)
+				return err
+			},
+		},
+		{
+			name: "security entity map",
+			parse: func() error {
+				_, err := testFIParser.parseFIs(wrapInReadCloser(``), wrapInReadCloser(`"FSYM_ID"|"ENTITY_ID"`), nil)
+				return err
+			},
+		},
+		{
+			name: "listings",
+			parse: func() error {
+				_, err := testFIParser.parseListings(wrapInReadCloser(`"FSYM_ID"|"CURRENCY"`), nil, nil)
+				return err
+			},
+		},
+		{
+			name: "figi codes",
+			parse: func() error {
+				_, _, err := testFIParser.parseFIGICodes(wrapInReadCloser(`"FSYM_ID"|"FIGI"`), nil, nil)
+				return err
+			},
+		},
+		{
+			name: "listing codes",
+			parse: func() error {
+				_, err := testFIParser.parseListingCodes(wrapInReadCloser(`"FSYM_ID"|"BBG_ID"`), nil, nil)
+				return err
+			},
+		},
+		{
+			name: "entities",
+			parse: func() error {
+				_, err := testFIParser.parseEntityFunc()(wrapInReadCloser(`"FACTSET_ENTITY_ID"|"ISO_COUNTRY"`), nil)
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		err := tc.parse()
+		if errors.Cause(err) != errMissingColumn {
+			t.Errorf("Case [%s]. Expected: [%v]. Actual: [%v]", tc.name, errMissingColumn, err)
+		}
+	}
+}
+
 func TestParseListings(t *testing.T) {
 	headerLine := `"FSYM_ID"|"CURRENCY"|"PROPER_NAME"|"FSYM_PRIMARY_EQUITY_ID"|"FSYM_PRIMARY_LISTING_ID"|"ACTIVE_FLAG"|"FREF_SECURITY_TYPE"|"FREF_LISTING_EXCHANGE"|"LISTING_FLAG"|"REGIONAL_FLAG"|"SECURITY_FLAG"|"FSYM_REGIONAL_ID"|"FSYM_SECURITY_ID"|"UNIVERSE_TYPE"`
 	var testCases = []struct {
@@ -149,9 +275,9 @@ func TestParseListings(t *testing.T) {
 			},
 			expected: map[string]string{},
 		},
-		// no primary equity
+		// no security
 		{
-			listings: `"H73FN8-R"|"GBP"|"Ralph Martindale & Company Ltd"|""|"MLKNP9-L"|1|"SHARE"|"LON"|0|1|0|"H73FN8-R"|"GG9B0P-S"|"EQ"`,
+			listings: `"H73FN8-R"|"GBP"|"Ralph Martindale & Company Ltd"|"GG9B0P-S"|"MLKNP9-L"|1|"SHARE"|"LON"|0|1|0|"H73FN8-R"|""|"EQ"`,
 			secIDToRawFI: map[string]rawFinancialInstrument{
 				"GG9B0P-S": rawFinancialInstrument{
 					securityID:       "GG9B0P-S",
@@ -171,7 +297,7 @@ func TestParseListings(t *testing.T) {
 		},
 		// FI exist, but primary Listing ID does not match
 		{
-			listings: `"H73FN8-R"|"GBP"|"Ralph Martindale & Company Ltd"|"GG9B0P-S""|"MLKNP9-L"|1|"SHARE"|"LON"|0|1|0|"H73FN8-R"|"GG9B0P-S"|"EQ"`,
+			listings: `"H73FN8-R"|"GBP"|"Ralph Martindale & Company Ltd"|"GG9B0P-S"|"MLKNP9-L"|1|"SHARE"|"LON"|0|1|0|"H73FN8-R"|"GG9B0P-S"|"EQ"`,
 			secIDToRawFI: map[string]rawFinancialInstrument{
 				"GG9B0P-S": rawFinancialInstrument{
 					securityID:       "GG9B0P-S",
@@ -185,7 +311,7 @@ func TestParseListings(t *testing.T) {
 		},
 		// no primary listing ID
 		{
-			listings: `"H73FN8-R"|"GBP"|"Ralph Martindale & Company Ltd"|"GG9B0P-S""|""|1|"SHARE"|"LON"|0|1|0|"H73FN8-R"|"GG9B0P-S"|"EQ"`,
+			listings: `"H73FN8-R"|"GBP"|"Ralph Martindale & Company Ltd"|"GG9B0P-S"|""|1|"SHARE"|"LON"|0|1|0|"H73FN8-R"|"GG9B0P-S"|"EQ"`,
 			secIDToRawFI: map[string]rawFinancialInstrument{
 				"GG9B0P-S": rawFinancialInstrument{
 					securityID:       "GG9B0P-S",
@@ -199,7 +325,7 @@ func TestParseListings(t *testing.T) {
 		},
 		// happy case
 		{
-			listings: `"H73FN8-R"|"GBP"|"Ralph Martindale & Company Ltd"|"GG9B0P-S""|"MLKNP9-L"|1|"SHARE"|"LON"|0|1|0|"H73FN8-R"|"GG9B0P-S"|"EQ"`,
+			listings: `"H73FN8-R"|"GBP"|"Ralph Martindale & Company Ltd"|"GG9B0P-S"|"MLKNP9-L"|1|"SHARE"|"LON"|0|1|0|"H73FN8-R"|"GG9B0P-S"|"EQ"`,
 			secIDToRawFI: map[string]rawFinancialInstrument{
 				"GG9B0P-S": rawFinancialInstrument{
 					securityID:       "GG9B0P-S",
@@ -216,7 +342,10 @@ func TestParseListings(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		actual := testFIParser.parseListings(wrapInReadCloser(headerLine+"\n"+tc.listings), tc.secIDToRawFI)
+		actual, err := testFIParser.parseListings(wrapInReadCloser(headerLine+"\n"+tc.listings), tc.secIDToRawFI, nil)
+		if err != nil {
+			t.Error(err)
+		}
 
 		if !reflect.DeepEqual(actual, tc.expected) {
 			t.Errorf("Expected: [%v]. Actual: [%v]", tc.expected, actual)
@@ -251,7 +380,7 @@ func TestParseFIGICodes(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		figis, err := testFIParser.parseFIGICodes(wrapInReadCloser(headerLine+"\n"+tc.figis), tc.listings)
+		figis, _, err := testFIParser.parseFIGICodes(wrapInReadCloser(headerLine+"\n"+tc.figis), tc.listings, nil)
 		if err != nil {
 			t.Error(err)
 		}
@@ -261,34 +390,178 @@ func TestParseFIGICodes(t *testing.T) {
 	}
 }
 
+func TestParseFIGICodes_FIGISharedBySecurities_LowestSecurityIDKeepsIt(t *testing.T) {
+	figis := `"FSYM_ID"|"BBG_ID"|"BBG_TICKER"` + "\n" +
+		`"M679DF-L"|"BBG000JPVHS1"|"IPMB SG"` + "\n" +
+		`"V0CJ4K-L"|"BBG000JPVHS1"|"IPMB SG"` + "\n" +
+		`"MLKNP9-L"|"BBG000BDN0W4"|"MKS LN"`
+	listings := map[string]string{
+		"M679DF-L": "JBP7Z8-S",
+		"V0CJ4K-L": "JBP7Z7-S",
+		"MLKNP9-L": "GG9B0P-S",
+	}
+
+	actual, conflicts, err := testFIParser.parseFIGICodes(wrapInReadCloser(figis), listings, nil)
+
+	if err != nil {
+		t.Error(err)
+	}
+	expected := map[string]string{
+		"BBG000JPVHS1": "JBP7Z7-S",
+		"BBG000BDN0W4": "GG9B0P-S",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expected, actual)
+	}
+	expectedConflicts := []figiConflict{
+		{
+			Reason:    figiSharedBySecurities,
+			Kept:      conflictRecord{FactsetID: "JBP7Z7-S", FIGI: "BBG000JPVHS1"},
+			Discarded: []conflictRecord{{FactsetID: "JBP7Z8-S", FIGI: "BBG000JPVHS1"}},
+		},
+	}
+	if !reflect.DeepEqual(conflicts, expectedConflicts) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expectedConflicts, conflicts)
+	}
+}
+
+func TestParseListings_RecordsAllRegionalListings(t *testing.T) {
+	listings := `"FSYM_ID"|"CURRENCY"|"PROPER_NAME"|"FSYM_PRIMARY_EQUITY_ID"|"FSYM_PRIMARY_LISTING_ID"|"ACTIVE_FLAG"|"FREF_SECURITY_TYPE"|"FREF_LISTING_EXCHANGE"|"LISTING_FLAG"|"REGIONAL_FLAG"|"SECURITY_FLAG"|"FSYM_REGIONAL_ID"|"FSYM_SECURITY_ID"|"UNIVERSE_TYPE"` + "\n" +
+		`"H73FN8-R"|"GBP"|"Marks & Spencer Group Plc"|"GG9B0P-S"|"MLKNP9-L"|1|"SHARE"|"LON"|0|1|0|"H73FN8-R"|"GG9B0P-S"|"EQ"` + "\n" +
+		`"R0K3M2-R"|"USD"|"Marks & Spencer Group Plc"|"GG9B0P-S"|"T9X6PL-L"|1|"SHARE"|"OTC"|0|1|0|"R0K3M2-R"|"GG9B0P-S"|"EQ"`
+	fis := map[string]rawFinancialInstrument{
+		"GG9B0P-S": {
+			securityID:       "GG9B0P-S",
+			fiType:           "EQ",
+			securityName:     "Marks & Spencer Group Plc",
+			primaryListingID: "H73FN8-R",
+		},
+	}
+
+	actual, err := testFIParser.parseListings(wrapInReadCloser(listings), fis, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := map[string]string{"MLKNP9-L": "GG9B0P-S"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expected, actual)
+	}
+	expected := rawFinancialInstrument{
+		securityID:       "GG9B0P-S",
+		fiType:           "EQ",
+		securityName:     "Marks & Spencer Group Plc",
+		primaryListingID: "H73FN8-R",
+		exchange:         "LON",
+		currency:         "GBP",
+		listings: []listing{
+			{listingID: "MLKNP9-L", exchange: "LON", currency: "GBP", primary: true},
+			{listingID: "T9X6PL-L", exchange: "OTC", currency: "USD"},
+		},
+	}
+	if !reflect.DeepEqual(fis["GG9B0P-S"], expected) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expected, fis["GG9B0P-S"])
+	}
+}
+
+func TestParseListings_SecuritiesOfTheSameIssuer(t *testing.T) {
+	listings := `"FSYM_ID"|"CURRENCY"|"PROPER_NAME"|"FSYM_PRIMARY_EQUITY_ID"|"FSYM_PRIMARY_LISTING_ID"|"ACTIVE_FLAG"|"FREF_SECURITY_TYPE"|"FREF_LISTING_EXCHANGE"|"LISTING_FLAG"|"REGIONAL_FLAG"|"SECURITY_FLAG"|"FSYM_REGIONAL_ID"|"FSYM_SECURITY_ID"|"UNIVERSE_TYPE"` + "\n" +
+		`"H73FN8-R"|"GBP"|"Marks & Spencer Group Plc"|"GG9B0P-S"|"MLKNP9-L"|1|"SHARE"|"LON"|0|1|0|"H73FN8-R"|"GG9B0P-S"|"EQ"` + "\n" +
+		`"MLKNP9-L"|"GBP"|"Marks & Spencer Group Plc"|"GG9B0P-S"|"MLKNP9-L"|1|"SHARE"|"LON"|1|0|0|"H73FN8-R"|"GG9B0P-S"|"EQ"` + "\n" +
+		`"B7WQ2D-L"|"GBX"|"Marks & Spencer Group Plc"|"GG9B0P-S"|"MLKNP9-L"|1|"SHARE"|"BAT"|1|0|0|"H73FN8-R"|"GG9B0P-S"|"EQ"` + "\n" +
+		`"PREF0R-R"|"GBP"|"Marks & Spencer Group Plc Pref"|"GG9B0P-S"|"PREF0L-L"|1|"PREFEQ"|"LON"|0|1|0|"PREF0R-R"|"PREF01-S"|"EQ"` + "\n" +
+		`"PREF0L-L"|"GBP"|"Marks & Spencer Group Plc Pref"|"GG9B0P-S"|"PREF0L-L"|1|"PREFEQ"|"LON"|1|0|0|"PREF0R-R"|"PREF01-S"|"EQ"`
+	fis := map[string]rawFinancialInstrument{
+		"GG9B0P-S": {securityID: "GG9B0P-S", primaryListingID: "H73FN8-R"},
+		"PREF01-S": {securityID: "PREF01-S", primaryListingID: "PREF0R-R"},
+	}
+
+	actual, err := testFIParser.parseListings(wrapInReadCloser(listings), fis, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := map[string]string{"MLKNP9-L": "GG9B0P-S", "PREF0L-L": "PREF01-S"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expected, actual)
+	}
+	expected := map[string]rawFinancialInstrument{
+		"GG9B0P-S": {
+			securityID:       "GG9B0P-S",
+			primaryListingID: "H73FN8-R",
+			exchange:         "LON",
+			currency:         "GBP",
+			listings: []listing{
+				{listingID: "MLKNP9-L", exchange: "LON", currency: "GBP", primary: true},
+				{listingID: "B7WQ2D-L", exchange: "BAT", currency: "GBX"},
+			},
+		},
+		"PREF01-S": {
+			securityID:       "PREF01-S",
+			primaryListingID: "PREF0R-R",
+			exchange:         "LON",
+			currency:         "GBP",
+			listings:         []listing{{listingID: "PREF0L-L", exchange: "LON", currency: "GBP", primary: true}},
+		},
+	}
+	if !reflect.DeepEqual(fis, expected) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expected, fis)
+	}
+}
+
+func TestParseListingCodes(t *testing.T) {
+	codes := `"FSYM_ID"|"BBG_ID"|"BBG_TICKER"` + "\n" +
+		`"M679DF-L"|"BBG000JPVHS1"|"IPMB SG"` + "\n" +
+		`"T9X6PL-L"|"BBG000BDN1K7"|""` + "\n" +
+		`"V0CJ4K-L"|"BBG000CXWV71"|"RMC LN"`
+	fis := map[string]rawFinancialInstrument{
+		"JBP7Z8-S": {securityID: "JBP7Z8-S", listings: []listing{{listingID: "M679DF-L", primary: true}}},
+		"GG9B0P-S": {securityID: "GG9B0P-S", listings: []listing{{listingID: "MLKNP9-L", primary: true}, {listingID: "T9X6PL-L"}}},
+	}
+
+	actual, err := testFIParser.parseListingCodes(wrapInReadCloser(codes), fis, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]listingCodes{
+		"M679DF-L": {figiCode: "BBG000JPVHS1", ticker: "IPMB SG"},
+		"T9X6PL-L": {figiCode: "BBG000BDN1K7"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expected, actual)
+	}
+}
+
 func TestParseEntities(t *testing.T) {
 	headerLine := `"FACTSET_ENTITY_ID"|"ENTITY_NAME"|"ENTITY_PROPER_NAME"|"PRIMARY_SIC_CODE"|"INDUSTRY_CODE"|"SECTOR_CODE"|"ISO_COUNTRY"|"METRO_AREA"|"STATE_PROVINCE"|"ZIP_POSTAL_CODE"|"WEB_SITE"|"ENTITY_TYPE"|"ENTITY_SUB_TYPE"|"YEAR_FOUNDED"|"ISO_COUNTRY_INCORP"|"ISO_COUNTRY_COR"|"NACE_CODE"`
 	var testCases = []struct {
 		entities string
-		expected map[string]bool
+		expected map[string]string
 	}{
 		{
 			entities: ``,
-			expected: map[string]bool{},
+			expected: map[string]string{},
 		},
 		// no PUB entity
 		{
 			entities: `"04CXMV-E"|"Beta International - Valor"|"Beta International - Valor"|"6722"|"6010"|"6000"|"LU"|""|""|""|""|"MUT"|""||"LU"|""|"64.30"` + "\n" +
 				`"007BPZ-E"|"MORTGAGE PARTNERS LENDING CORP"|"Mortgage Partners Lending Corp."|""|""|""|"US"|"Denver/CO Metro"|"CO"|"80120"|""|"PVT"|"CP"||"US"|""|""`,
-			expected: map[string]bool{},
+			expected: map[string]string{},
 		},
 		// only PUB entity is returned
 		{
 			entities: `"04CXMV-E"|"Beta International - Valor"|"Beta International - Valor"|"6722"|"6010"|"6000"|"LU"|""|""|""|""|"MUT"|""||"LU"|""|"64.30"` + "\n" +
 				`"05G2M9-E"|"MARKS & SPENCER GROUP PLC"|"Marks & Spencer Group Plc"|"5311"|"3515"|"3500"|"GB"|"London/UK Metro"|"LO"|"W2 1NW"|"corporate.marksandspencer.com"|"PUB"|"CP"|1884|"GB"|"GB"|"47.19"`,
-			expected: map[string]bool{
-				"05G2M9-E": true,
+			expected: map[string]string{
+				"05G2M9-E": "GB",
 			},
 		},
 	}
 
 	for _, tc := range testCases {
-		pubEntities := testFIParser.parseEntityFunc()(wrapInReadCloser(headerLine + "\n" + tc.entities))
+		pubEntities, err := testFIParser.parseEntityFunc()(wrapInReadCloser(headerLine+"\n"+tc.entities), nil)
+		if err != nil {
+			t.Error(err)
+		}
 		if !reflect.DeepEqual(pubEntities, tc.expected) {
 			t.Errorf("Expected: [%v]. Actual: [%v]", tc.expected, pubEntities)
 		}
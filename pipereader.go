@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// pipeReader reads the records of a Factset pipe-delimited file. A field enclosed in double quotes can hold pipes,
+// line breaks and double quotes, the latter escaped by doubling them. A record breaking these rules is malformed:
+// it is logged with its line number and passed over, and reading goes on with the next record.
+type pipeReader struct {
+	file      string
+	reader    *csv.Reader
+	record    []string
+	badLine   int
+	readError error
+}
+
+func newPipeReader(r io.Reader, file string) *pipeReader {
+	reader := csv.NewReader(r)
+	reader.Comma = '|'
+	reader.FieldsPerRecord = -1
+	return &pipeReader{file: file, reader: reader}
+}
+
+// next advances to the next record, well-formed or not. It returns false at the end of the file,
+// or when the file cannot be read any further, which err then reports.
+func (p *pipeReader) next() bool {
+	p.record, p.badLine = nil, 0
+	record, err := p.reader.Read()
+	if err == io.EOF {
+		return false
+	}
+	if perr, ok := err.(*csv.ParseError); ok {
+		p.badLine = perr.StartLine
+		warnLogger.Printf("Skip malformed record of [%s] at line [%d]: [%v]", p.file, perr.StartLine, perr.Err)
+		return true
+	}
+	if err != nil {
+		p.readError = errors.Wrapf(err, "could not read file [%s]", p.file)
+		return false
+	}
+	p.record = record
+	return true
+}
+
+// malformed returns the line the current record starts at, if the record is malformed.
+func (p *pipeReader) malformed() (int, bool) {
+	return p.badLine, p.badLine > 0
+}
+
+// fields returns the fields of the current record, nil if the record is malformed.
+func (p *pipeReader) fields() []string {
+	return p.record
+}
+
+func (p *pipeReader) err() error {
+	return p.readError
+}
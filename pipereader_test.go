@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeReader_QuotedFields(t *testing.T) {
+	file := `"FSYM_ID"|"PROPER_NAME"|"ACTIVE_FLAG"` + "\n" +
+		`"ABCDEF-S"|"Foo | Bar Holdings"|1` + "\n" +
+		`"FEDCBA-S"|"The ""Baz"" Company"|` + "\n" +
+		`"MNBVCX-S"|"Multi` + "\n" + `Line Inc"|0`
+
+	rows := newPipeReader(strings.NewReader(file), securities)
+
+	var records [][]string
+	for rows.next() {
+		_, malformed := rows.malformed()
+		require.False(t, malformed)
+		records = append(records, rows.fields())
+	}
+	require.NoError(t, rows.err())
+	assert.Equal(t, [][]string{
+		{"FSYM_ID", "PROPER_NAME", "ACTIVE_FLAG"},
+		{"ABCDEF-S", "Foo | Bar Holdings", "1"},
+		{"FEDCBA-S", `The "Baz" Company`, ""},
+		{"MNBVCX-S", "Multi\nLine Inc", "0"},
+	}, records)
+}
+
+func TestPipeReader_MalformedRecordsAreSkippedWithTheirLine(t *testing.T) {
+	file := `"FSYM_ID"|"PROPER_NAME"` + "\n" +
+		`"ABCDEF-S"|"Foo "Bar" Holdings"` + "\n" +
+		`"FEDCBA-S"|"Baz Inc"` + "\n" +
+		`"MNBVCX-S"|Qux "Q" Ltd`
+
+	rows := newPipeReader(strings.NewReader(file), securities)
+
+	var malformedLines []int
+	var records [][]string
+	for rows.next() {
+		if line, malformed := rows.malformed(); malformed {
+			assert.Nil(t, rows.fields())
+			malformedLines = append(malformedLines, line)
+			continue
+		}
+		records = append(records, rows.fields())
+	}
+	require.NoError(t, rows.err())
+	assert.Equal(t, []int{2, 4}, malformedLines)
+	assert.Equal(t, [][]string{{"FSYM_ID", "PROPER_NAME"}, {"FEDCBA-S", "Baz Inc"}}, records)
+}
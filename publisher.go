@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	financialInstrumentMessageType = "financial-instrument-published"
+	factsetOriginSystemID          = "http://api.ft.com/system/FACTSET"
+	messageTimestampFormat         = "2006-01-02T15:04:05.000Z"
+
+	// publishBatchSize is the number of messages sent to Kafka in one request.
+	publishBatchSize = 500
+)
+
+// ftMessage is a message in the FT message format: the FTMSG/1.0 preamble, one "Name: value" header per line,
+// a blank line and the body.
+type ftMessage struct {
+	Headers map[string]string
+	Body    string
+}
+
+func (m ftMessage) build() string {
+	names := make([]string, 0, len(m.Headers))
+	for name := range m.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteString("FTMSG/1.0\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", name, m.Headers[name])
+	}
+	b.WriteString("\n")
+	b.WriteString(m.Body)
+	return b.String()
+}
+
+type keyedMessage struct {
+	key     string
+	message ftMessage
+}
+
+// messageProducer sends messages to the queue, keyed so that all the messages of a financial instrument land on the same partition.
+type messageProducer interface {
+	SendMessages(msgs []keyedMessage) error
+}
+
+type kafkaProducer struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+func newKafkaProducer(brokers []string, topic string) (*kafkaProducer, error) {
+	config := sarama.NewConfig()
+	config.ClientID = "financial-instruments-transformer"
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 5
+	config.Producer.Return.Successes = true
+
+	producer, err := sarama.NewSyncProducer(brokers, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not connect to kafka brokers %v", brokers)
+	}
+	return &kafkaProducer{producer: producer, topic: topic}, nil
+}
+
+func (kp *kafkaProducer) SendMessages(msgs []keyedMessage) error {
+	kafkaMsgs := make([]*sarama.ProducerMessage, len(msgs))
+	for i, msg := range msgs {
+		kafkaMsgs[i] = &sarama.ProducerMessage{
+			Topic: kp.topic,
+			Key:   sarama.StringEncoder(msg.key),
+			Value: sarama.StringEncoder(msg.message.build()),
+		}
+	}
+	return kp.producer.SendMessages(kafkaMsgs)
+}
+
+// publisher emits every financial instrument of a transformed dataset as a message,
+// so that concept writers are pushed the instruments instead of crawling the transformer.
+type publisher struct {
+	producer messageProducer
+}
+
+func newPublisher(producer messageProducer) *publisher {
+	return &publisher{producer: producer}
+}
+
+// publish sends the financial instruments, and a message with an empty body for every removed financial instrument,
+// in UUID order, all under one transaction id so a publish run can be traced downstream.
+func (p *publisher) publish(fis map[string]financialInstrument, removed []string) error {
+	tid := "tid_" + uuid.NewRandom().String()
+	UUIDs := make([]string, 0, len(fis)+len(removed))
+	for UUID := range fis {
+		UUIDs = append(UUIDs, UUID)
+	}
+	UUIDs = append(UUIDs, removed...)
+	sort.Strings(UUIDs)
+
+	infoLogger.Printf("Publishing [%d] FIs and [%d] removals with transaction id [%s]", len(fis), len(removed), tid)
+	start := time.Now()
+	batch := make([]keyedMessage, 0, publishBatchSize)
+	for i, UUID := range UUIDs {
+		msg, err := newPublishedMessage(tid, UUID, fis)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, keyedMessage{key: UUID, message: msg})
+		if len(batch) == publishBatchSize || i == len(UUIDs)-1 {
+			if err := p.producer.SendMessages(batch); err != nil {
+				return errors.Wrapf(err, "could not publish FIs with transaction id [%s]", tid)
+			}
+			batch = make([]keyedMessage, 0, publishBatchSize)
+		}
+	}
+	infoLogger.Printf("Published [%d] messages with transaction id [%s] in [%v]", len(UUIDs), tid, time.Since(start))
+	return nil
+}
+
+// newPublishedMessage returns the message of the financial instrument, or the removal message when it is not among fis.
+func newPublishedMessage(tid string, UUID string, fis map[string]financialInstrument) (ftMessage, error) {
+	fi, present := fis[UUID]
+	if !present {
+		return newFIRemovedMessage(tid), nil
+	}
+	return newFIMessage(tid, UUID, fi)
+}
+
+func newFIMessage(tid string, UUID string, fi financialInstrument) (ftMessage, error) {
+	body, err := json.Marshal(toUppFI(UUID, fi))
+	if err != nil {
+		return ftMessage{}, err
+	}
+	return ftMessage{Headers: messageHeaders(tid), Body: string(body)}, nil
+}
+
+// newFIRemovedMessage tells downstream that the financial instrument of the message key was removed, with an empty body.
+func newFIRemovedMessage(tid string) ftMessage {
+	return ftMessage{Headers: messageHeaders(tid)}
+}
+
+func messageHeaders(tid string) map[string]string {
+	return map[string]string{
+		"Message-Id":        uuid.NewRandom().String(),
+		"Message-Type":      financialInstrumentMessageType,
+		"Message-Timestamp": time.Now().UTC().Format(messageTimestampFormat),
+		"Origin-System-Id":  factsetOriginSystemID,
+		"Content-Type":      "application/json",
+		"X-Request-Id":      tid,
+	}
+}
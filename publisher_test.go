@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inMemoryBroker stands in for Kafka: it keeps the messages sent to it in the order they were received.
+type inMemoryBroker struct {
+	sync.Mutex
+	batches  int
+	messages []keyedMessage
+	err      error
+}
+
+func (b *inMemoryBroker) SendMessages(msgs []keyedMessage) error {
+	b.Lock()
+	defer b.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	b.batches++
+	b.messages = append(b.messages, msgs...)
+	return nil
+}
+
+func TestFTMessage_Build(t *testing.T) {
+	msg := ftMessage{
+		Headers: map[string]string{
+			"X-Request-Id": "tid_test",
+			"Message-Type": financialInstrumentMessageType,
+			"Content-Type": "application/json",
+		},
+		Body: `{"uuid":"404c8329-3f8e-348e-ba32-cf3eb2c1ffed"}`,
+	}
+
+	expected := "FTMSG/1.0\n" +
+		"Content-Type: application/json\n" +
+		"Message-Type: financial-instrument-published\n" +
+		"X-Request-Id: tid_test\n" +
+		"\n" +
+		`{"uuid":"404c8329-3f8e-348e-ba32-cf3eb2c1ffed"}`
+	assert.Equal(t, expected, msg.build())
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	fis := map[string]financialInstrument{
+		"8404dbec-2423-322d-9afa-f92e553e53b6": {
+			figiCode:     "BBG000BDN0W4",
+			securityID:   "GG9B0P-S",
+			orgID:        "21fbf032-23e7-34d3-970c-45432b455fd9",
+			securityName: "Marks and Spencer Group Plc",
+		},
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": {
+			figiCode:     "BBG000JPVHS1",
+			securityID:   "JBP7Z8-S",
+			orgID:        "ea90a425-73be-33c5-9aa4-939c9a46b87a",
+			securityName: "Industrija Precizne Mehanike AD",
+		},
+	}
+	broker := &inMemoryBroker{}
+
+	err := newPublisher(broker).publish(fis, nil)
+
+	require.NoError(t, err)
+	require.Len(t, broker.messages, 2)
+	assert.Equal(t, "404c8329-3f8e-348e-ba32-cf3eb2c1ffed", broker.messages[0].key)
+	assert.Equal(t, "8404dbec-2423-322d-9afa-f92e553e53b6", broker.messages[1].key)
+
+	tid := broker.messages[0].message.Headers["X-Request-Id"]
+	assert.True(t, strings.HasPrefix(tid, "tid_"), "unexpected transaction id [%s]", tid)
+	for _, msg := range broker.messages {
+		headers := msg.message.Headers
+		assert.Equal(t, tid, headers["X-Request-Id"], "all the messages of a run share the transaction id")
+		assert.Equal(t, financialInstrumentMessageType, headers["Message-Type"])
+		assert.Equal(t, factsetOriginSystemID, headers["Origin-System-Id"])
+		assert.NotEmpty(t, headers["Message-Id"])
+		assert.NotEmpty(t, headers["Message-Timestamp"])
+
+		var actual uppFI
+		require.NoError(t, json.Unmarshal([]byte(msg.message.Body), &actual))
+		assert.Equal(t, toUppFI(msg.key, fis[msg.key]), actual)
+	}
+	assert.NotEqual(t, broker.messages[0].message.Headers["Message-Id"], broker.messages[1].message.Headers["Message-Id"])
+}
+
+func TestPublisher_Publish_SendsInBatches(t *testing.T) {
+	fis := make(map[string]financialInstrument)
+	for i := 0; i < publishBatchSize+1; i++ {
+		fis[fmt.Sprintf("uuid-%04d", i)] = financialInstrument{securityID: fmt.Sprintf("S%04d-S", i)}
+	}
+	broker := &inMemoryBroker{}
+
+	err := newPublisher(broker).publish(fis, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, broker.batches)
+	require.Len(t, broker.messages, publishBatchSize+1)
+	assert.Equal(t, "uuid-0000", broker.messages[0].key)
+	assert.Equal(t, fmt.Sprintf("uuid-%04d", publishBatchSize), broker.messages[publishBatchSize].key)
+}
+
+func TestPublisher_Publish_ProducerFails(t *testing.T) {
+	broker := &inMemoryBroker{err: errors.New("leader not available")}
+
+	err := newPublisher(broker).publish(map[string]financialInstrument{"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": {}}, nil)
+
+	assert.Error(t, err)
+}
+
+func TestPublisher_Publish_Removals(t *testing.T) {
+	fis := map[string]financialInstrument{"8404dbec-2423-322d-9afa-f92e553e53b6": {securityID: "GG9B0P-S"}}
+	broker := &inMemoryBroker{}
+
+	err := newPublisher(broker).publish(fis, []string{"404c8329-3f8e-348e-ba32-cf3eb2c1ffed"})
+
+	require.NoError(t, err)
+	require.Len(t, broker.messages, 2)
+	removal := broker.messages[0]
+	assert.Equal(t, "404c8329-3f8e-348e-ba32-cf3eb2c1ffed", removal.key)
+	assert.Empty(t, removal.message.Body)
+	assert.Equal(t, financialInstrumentMessageType, removal.message.Headers["Message-Type"])
+	assert.Equal(t, factsetOriginSystemID, removal.message.Headers["Origin-System-Id"])
+	assert.NotEmpty(t, removal.message.Headers["Message-Id"])
+	assert.Equal(t, broker.messages[1].message.Headers["X-Request-Id"], removal.message.Headers["X-Request-Id"])
+	assert.Equal(t, "8404dbec-2423-322d-9afa-f92e553e53b6", broker.messages[1].key)
+	assert.NotEmpty(t, broker.messages[1].message.Body)
+}
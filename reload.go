@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+const (
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+
+	// maxReloadJobs is the number of finished jobs kept for polling; older ones are forgotten.
+	maxReloadJobs = 50
+)
+
+type reloadJob struct {
+	ID       string    `json:"id"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+}
+
+// reloader runs fiService.Reload in the background on demand, one reload at a time,
+// and keeps track of the outcome of the latest reloads.
+type reloader struct {
+	sync.Mutex
+	fiService fiService
+	jobs      map[string]*reloadJob
+	jobIDs    []string
+	running   *reloadJob
+}
+
+func newReloader(s fiService) *reloader {
+	return &reloader{
+		fiService: s,
+		jobs:      make(map[string]*reloadJob),
+	}
+}
+
+// start kicks off a reload and returns its job. If a reload is already in progress,
+// no new one is started and the running job is returned instead.
+func (r *reloader) start() (reloadJob, bool) {
+	r.Lock()
+	defer r.Unlock()
+	if r.running != nil {
+		return *r.running, false
+	}
+
+	job := &reloadJob{
+		ID:      uuid.NewRandom().String(),
+		Status:  jobRunning,
+		Started: time.Now(),
+	}
+	r.jobs[job.ID] = job
+	r.jobIDs = append(r.jobIDs, job.ID)
+	if len(r.jobIDs) > maxReloadJobs {
+		delete(r.jobs, r.jobIDs[0])
+		r.jobIDs = r.jobIDs[1:]
+	}
+	r.running = job
+
+	infoLogger.Printf("Starting reload job [%s]", job.ID)
+	go r.run(job)
+	return *job, true
+}
+
+func (r *reloader) run(job *reloadJob) {
+	err := r.fiService.Reload()
+
+	r.Lock()
+	defer r.Unlock()
+	job.Finished = time.Now()
+	if err != nil {
+		job.Status = jobFailed
+		job.Error = err.Error()
+		errorLogger.Printf("Reload job [%s] failed: [%v]", job.ID, err)
+	} else {
+		job.Status = jobSucceeded
+		infoLogger.Printf("Reload job [%s] finished in [%v]", job.ID, job.Finished.Sub(job.Started))
+	}
+	r.running = nil
+}
+
+func (r *reloader) job(id string) (reloadJob, bool) {
+	r.Lock()
+	defer r.Unlock()
+	job, present := r.jobs[id]
+	if !present {
+		return reloadJob{}, false
+	}
+	return *job, true
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func blockingService(release <-chan struct{}, err error) *fiServiceImpl {
+	return &fiServiceImpl{
+		fit: &transformerMock{
+			mockFindLatestResourcesFolder: func() (string, error) {
+				return "2017-08-10", nil
+			},
+			mockTransform: func() (map[string]financialInstrument, error) {
+				<-release
+				return map[string]financialInstrument{}, err
+			},
+		},
+	}
+}
+
+func waitForJob(t *testing.T, r *reloader, id string) reloadJob {
+	for i := 0; i < 100; i++ {
+		job, present := r.job(id)
+		require.True(t, present)
+		if job.Status != jobRunning {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Reload job [%s] did not finish", id)
+	return reloadJob{}
+}
+
+func TestReloader_SecondReloadWhileRunning_RunningJobIsReturned(t *testing.T) {
+	release := make(chan struct{})
+	r := newReloader(blockingService(release, nil))
+
+	first, started := r.start()
+	assert.True(t, started)
+	assert.Equal(t, jobRunning, first.Status)
+
+	second, started := r.start()
+	assert.False(t, started)
+	assert.Equal(t, first.ID, second.ID)
+
+	close(release)
+	job := waitForJob(t, r, first.ID)
+	assert.Equal(t, jobSucceeded, job.Status)
+	assert.False(t, job.Finished.Before(job.Started))
+
+	third, started := r.start()
+	assert.True(t, started)
+	assert.NotEqual(t, first.ID, third.ID)
+	waitForJob(t, r, third.ID)
+}
+
+func TestReloader_ReloadFails_ErrorIsReported(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	r := newReloader(blockingService(release, errors.New("broken zip")))
+
+	job, _ := r.start()
+	job = waitForJob(t, r, job.ID)
+
+	assert.Equal(t, jobFailed, job.Status)
+	assert.Equal(t, "broken zip", job.Error)
+}
+
+func TestReloader_ReloadFails_JobFailsWithoutRetrying(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	fis := blockingService(release, errors.New("broken zip"))
+	fis.backoff = backoffConfig{initialInterval: time.Hour, maxInterval: time.Hour, deadline: time.Hour}
+	r := newReloader(fis)
+
+	job, _ := r.start()
+	// a retry would only come after an hour, long after waitForJob gives up
+	job = waitForJob(t, r, job.ID)
+
+	assert.Equal(t, jobFailed, job.Status)
+	_, started := r.start()
+	assert.True(t, started)
+}
+
+func TestReloader_UnknownJob(t *testing.T) {
+	r := newReloader(&fiServiceImpl{})
+
+	_, present := r.job("c6a0a1f5-0e9e-4b5b-9b8c-1c1bd5fbb1a7")
+
+	assert.False(t, present)
+}
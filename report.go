@@ -0,0 +1,115 @@
+package main
+
+import "time"
+
+// reasons for skipping a row, reported per stage in the transform report
+const (
+	skipIncompleteRecord         = "incompleteRecord"
+	skipMalformedRecord          = "malformedRecord"
+	skipInvalidActiveFlag        = "invalidActiveFlag"
+	skipUniverseTypeRejected     = "universeTypeRejected"
+	skipSecurityIDSuffixRejected = "securityIdSuffixRejected"
+	skipInactive                 = "inactive"
+	skipNotPrimaryEquity         = "notPrimaryEquity"
+	skipSecurityTypeRejected     = "securityTypeRejected"
+	skipNoPrimaryListing         = "noPrimaryListing"
+	skipUnknownSecurity          = "unknownSecurity"
+	skipNotRegional              = "notRegional"
+	skipUnknownListing           = "unknownListing"
+	skipEntityTypeRejected       = "entityTypeRejected"
+)
+
+// transformReport describes a transformation run: the rows read and skipped by every parsing stage,
+// and how many financial instruments were dropped on the way to the final count.
+type transformReport struct {
+	ResourcesFolder      string        `json:"resourcesFolder"`
+	DailyFolders         []string      `json:"dailyFolders,omitempty"`
+	Started              time.Time     `json:"started"`
+	Finished             time.Time     `json:"finished"`
+	Stages               []*stageStats `json:"stages"`
+	FilteredAsNonPublic  int           `json:"filteredAsNonPublic"`
+	WithoutFIGI          int           `json:"withoutFigi"`
+	FinancialInstruments int           `json:"financialInstruments"`
+}
+
+// maxMalformedLines caps the line numbers of malformed records kept per stage, the log has them all.
+const maxMalformedLines = 100
+
+type stageStats struct {
+	Stage          string         `json:"stage"`
+	File           string         `json:"file"`
+	RowsRead       int            `json:"rowsRead"`
+	RowsSkipped    int            `json:"rowsSkipped"`
+	SkipReasons    map[string]int `json:"skipReasons"`
+	MalformedLines []int          `json:"malformedLines,omitempty"`
+	Rules          []*ruleStats   `json:"rules,omitempty"`
+}
+
+// ruleStats counts the records accepted and rejected by a selection rule. The rules are checked in order,
+// and a record rejected by a rule is not checked against the following ones.
+type ruleStats struct {
+	Rule     string `json:"rule"`
+	Accepted int    `json:"accepted"`
+	Rejected int    `json:"rejected"`
+}
+
+// stage adds the statistics of a parsing stage to the report. It returns nil on a nil report,
+// and the stageStats methods do nothing on nil, so parsers can be called without a report.
+func (r *transformReport) stage(name string, file string) *stageStats {
+	if r == nil {
+		return nil
+	}
+	s := &stageStats{Stage: name, File: file, SkipReasons: make(map[string]int)}
+	r.Stages = append(r.Stages, s)
+	return s
+}
+
+func (s *stageStats) read() {
+	if s == nil {
+		return
+	}
+	s.RowsRead++
+}
+
+func (s *stageStats) skip(reason string) {
+	if s == nil {
+		return
+	}
+	s.RowsSkipped++
+	s.SkipReasons[reason]++
+}
+
+// skipMalformed skips a malformed record, keeping the line it starts at.
+func (s *stageStats) skipMalformed(line int) {
+	if s == nil {
+		return
+	}
+	s.skip(skipMalformedRecord)
+	if len(s.MalformedLines) < maxMalformedLines {
+		s.MalformedLines = append(s.MalformedLines, line)
+	}
+}
+
+// rule counts a record accepted or rejected by the named selection rule, and returns whether it was accepted.
+func (s *stageStats) rule(name string, accepted bool) bool {
+	if s == nil {
+		return accepted
+	}
+	var stats *ruleStats
+	for _, r := range s.Rules {
+		if r.Rule == name {
+			stats = r
+			break
+		}
+	}
+	if stats == nil {
+		stats = &ruleStats{Rule: name}
+		s.Rules = append(s.Rules, stats)
+	}
+	if accepted {
+		stats.Accepted++
+	} else {
+		stats.Rejected++
+	}
+	return accepted
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// names of the selection rules, as reported per stage in the transform report
+const (
+	ruleUniverseType     = "universeType"
+	ruleSecurityIDSuffix = "securityIdSuffix"
+	ruleActive           = "active"
+	rulePrimaryEquity    = "primaryEquity"
+	ruleSecurityType     = "securityType"
+	ruleEntityType       = "entityType"
+)
+
+// selectionRules decide which Factset securities are transformed into financial instruments,
+// and which entities may issue them.
+type selectionRules struct {
+	UniverseTypes      []string `json:"universeTypes"`
+	SecurityTypes      []string `json:"securityTypes"`
+	SecurityIDSuffixes []string `json:"securityIdSuffixes"`
+	ActiveOnly         bool     `json:"activeOnly"`
+	PrimaryEquityOnly  bool     `json:"primaryEquityOnly"`
+	EntityTypes        []string `json:"entityTypes"`
+}
+
+// defaultSelectionRules select the active primary shares of the equity universe issued by public entities.
+var defaultSelectionRules = selectionRules{
+	UniverseTypes:      []string{"EQ"},
+	SecurityTypes:      []string{"SHARE"},
+	SecurityIDSuffixes: []string{"-S"},
+	ActiveOnly:         true,
+	PrimaryEquityOnly:  true,
+	EntityTypes:        []string{"PUB"},
+}
+
+// loadSelectionRules reads the rules from a JSON file. The rules left out of the file keep their default value.
+func loadSelectionRules(path string) (selectionRules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return selectionRules{}, err
+	}
+	defer f.Close()
+
+	rules := defaultSelectionRules
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rules); err != nil {
+		return selectionRules{}, errors.Wrapf(err, "could not decode selection rules [%s]", path)
+	}
+	if err := rules.validate(); err != nil {
+		return selectionRules{}, errors.Wrapf(err, "invalid selection rules [%s]", path)
+	}
+	return rules, nil
+}
+
+func (r selectionRules) validate() error {
+	lists := []struct {
+		name   string
+		values []string
+	}{
+		{"universeTypes", r.UniverseTypes},
+		{"securityTypes", r.SecurityTypes},
+		{"securityIdSuffixes", r.SecurityIDSuffixes},
+		{"entityTypes", r.EntityTypes},
+	}
+	for _, l := range lists {
+		if len(l.values) == 0 {
+			return errors.Errorf("%s must not be empty", l.name)
+		}
+		for _, v := range l.values {
+			if strings.TrimSpace(v) == "" {
+				return errors.Errorf("%s must not contain blank values", l.name)
+			}
+		}
+	}
+	return nil
+}
+
+func (r selectionRules) hasSecurityIDSuffix(securityID string) bool {
+	for _, suffix := range r.SecurityIDSuffixes {
+		if strings.HasSuffix(securityID, suffix) {
+			return true
+		}
+	}
+	return false
+}
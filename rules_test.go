@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSelectionRules(t *testing.T, body string) string {
+	f, err := ioutil.TempFile("", "fis_test_rules")
+	require.NoError(t, err)
+	_, err = f.WriteString(body)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	return f.Name()
+}
+
+func TestLoadSelectionRules_OmittedRulesKeepTheirDefault(t *testing.T) {
+	path := writeSelectionRules(t, `{"universeTypes":["EQ","ET"],"securityTypes":["SHARE","PREFEQ","ADR","ETF_ETF"],"activeOnly":false}`)
+	defer os.Remove(path)
+
+	rules, err := loadSelectionRules(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, selectionRules{
+		UniverseTypes:      []string{"EQ", "ET"},
+		SecurityTypes:      []string{"SHARE", "PREFEQ", "ADR", "ETF_ETF"},
+		SecurityIDSuffixes: []string{"-S"},
+		ActiveOnly:         false,
+		PrimaryEquityOnly:  true,
+		EntityTypes:        []string{"PUB"},
+	}, rules)
+}
+
+func TestLoadSelectionRules_Invalid(t *testing.T) {
+	var testCases = []struct {
+		name string
+		body string
+	}{
+		{name: "not JSON", body: `universeTypes: [EQ]`},
+		{name: "unknown rule", body: `{"universeType":["EQ"]}`},
+		{name: "empty list", body: `{"entityTypes":[]}`},
+		{name: "blank value", body: `{"securityTypes":["SHARE"," "]}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeSelectionRules(t, tc.body)
+			defer os.Remove(path)
+
+			_, err := loadSelectionRules(path)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseFIs_CustomSelectionRules(t *testing.T) {
+	securities := `"FSYM_ID"|"CURRENCY"|"PROPER_NAME"|"FSYM_PRIMARY_EQUITY_ID"|"FSYM_PRIMARY_LISTING_ID"|"ACTIVE_FLAG"|"FREF_SECURITY_TYPE"|"FREF_LISTING_EXCHANGE"|"LISTING_FLAG"|"REGIONAL_FLAG"|"SECURITY_FLAG"|"FSYM_REGIONAL_ID"|"FSYM_SECURITY_ID"|"UNIVERSE_TYPE"` + "\n" +
+		`"JBP7Z8-S"|""|"Industrija Precizne Mehanike AD Pref"|"JBP7Z8-S"|"WHV8G2-R"|1|"PREFEQ"|""|0|0|1|"WHV8G2-R"|"JBP7Z8-S"|"EQ"` + "\n" +
+		`"K7TPSX-S"|""|"iShares Core FTSE 100"|"K7TPSX-S"|"Q3RR1L-R"|0|"ETF_ETF"|""|0|0|1|"Q3RR1L-R"|"K7TPSX-S"|"ET"` + "\n" +
+		`"GG9B0P-S"|""|"Marks & Spencer Group Plc"|"GG9B0P-S"|"H73FN8-R"|1|"SHARE"|""|0|0|1|"H73FN8-R"|"GG9B0P-S"|"EQ"`
+	rules := defaultSelectionRules
+	rules.UniverseTypes = []string{"EQ", "ET"}
+	rules.SecurityTypes = []string{"PREFEQ", "ETF_ETF"}
+	rules.ActiveOnly = false
+	report := &transformReport{}
+
+	fis, err := newFIParser(rules).parseFIs(wrapInReadCloser(securities), wrapInReadCloser(``), report)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]rawFinancialInstrument{
+		"JBP7Z8-S": {securityID: "JBP7Z8-S", fiType: "EQ", securityName: "Industrija Precizne Mehanike AD Pref", primaryListingID: "WHV8G2-R"},
+		"K7TPSX-S": {securityID: "K7TPSX-S", fiType: "ET", securityName: "iShares Core FTSE 100", primaryListingID: "Q3RR1L-R"},
+	}, fis)
+	assert.Equal(t, []*ruleStats{
+		{Rule: ruleUniverseType, Accepted: 3},
+		{Rule: ruleSecurityIDSuffix, Accepted: 3},
+		{Rule: ruleActive, Accepted: 3},
+		{Rule: rulePrimaryEquity, Accepted: 3},
+		{Rule: ruleSecurityType, Accepted: 2, Rejected: 1},
+	}, report.Stages[0].Rules)
+}
+
+func TestTransform_NotOnlyPrimaryEquities_PreferredShareHasItsOwnFIGI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fis_test_data")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeLocalDataset(t, dir, "2017-08-10")
+	// M&S issues a preferred share, listed in London under its own FIGI
+	writeLocalDaily(t, dir, "2017-08-14", map[string]string{
+		securities: `"FSYM_ID"|"CURRENCY"|"PROPER_NAME"|"FSYM_PRIMARY_EQUITY_ID"|"FSYM_PRIMARY_LISTING_ID"|"ACTIVE_FLAG"|"FREF_SECURITY_TYPE"|"FREF_LISTING_EXCHANGE"|"LISTING_FLAG"|"REGIONAL_FLAG"|"SECURITY_FLAG"|"FSYM_REGIONAL_ID"|"FSYM_SECURITY_ID"|"UNIVERSE_TYPE"` + "\n" +
+			`"PREF01-S"|""|"Marks & Spencer Group Plc Pref"|"GG9B0P-S"|"PREF0R-R"|1|"PREFEQ"|""|0|0|1|"PREF0R-R"|"PREF01-S"|"EQ"` + "\n" +
+			`"PREF0R-R"|"GBP"|"Marks & Spencer Group Plc Pref"|"GG9B0P-S"|"PREF0L-L"|1|"PREFEQ"|"LON"|0|1|0|"PREF0R-R"|"PREF01-S"|"EQ"` + "\n" +
+			`"PREF0L-L"|"GBP"|"Marks & Spencer Group Plc Pref"|"GG9B0P-S"|"PREF0L-L"|1|"PREFEQ"|"LON"|1|0|0|"PREF0R-R"|"PREF01-S"|"EQ"`,
+		securityEntityMap: `"FSYM_ID"|"FACTSET_ENTITY_ID"` + "\n" +
+			`"PREF01-S"|"05G2M9-E"`,
+		secToFIGIs: `"FSYM_ID"|"BBG_ID"|"BBG_TICKER"` + "\n" +
+			`"PREF0L-L"|"BBG000BDN1P4"|"MKSP LN"`,
+	})
+	rules := defaultSelectionRules
+	rules.SecurityTypes = []string{"SHARE", "PREFEQ"}
+	rules.PrimaryEquityOnly = false
+
+	l := newFSLoader(dir)
+	fit := &fiTransformerImpl{loader: &l, parser: newFIParser(rules)}
+	dailies, err := fit.findDailyFolders("2017-08-10")
+	require.NoError(t, err)
+	result, err := fit.Transform("2017-08-10", dailies)
+	require.NoError(t, err)
+
+	assert.Len(t, result.financialInstruments, 3)
+	assert.Equal(t, financialInstrument{
+		figiCode:      "BBG000BDN1P4",
+		securityID:    "PREF01-S",
+		orgID:         "21fbf032-23e7-34d3-970c-45432b455fd9",
+		securityName:  "Marks & Spencer Group Plc Pref",
+		ticker:        "MKSP LN",
+		exchange:      "LON",
+		currency:      "GBP",
+		issuerCountry: "GB",
+		listings: []listing{
+			{listingID: "PREF0L-L", figiCode: "BBG000BDN1P4", ticker: "MKSP LN", exchange: "LON", currency: "GBP", primary: true},
+		},
+	}, result.financialInstruments["1d540b92-9249-3ced-934c-22b628d4a03b"])
+	assert.Equal(t, "BBG000BDN0W4", result.financialInstruments["8404dbec-2423-322d-9afa-f92e553e53b6"].figiCode)
+}
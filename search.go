@@ -0,0 +1,246 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// scores of the ways a query token can match a token of a security name
+const (
+	scoreFuzzy  = 1
+	scorePrefix = 2
+	scoreExact  = 3
+	// scoreNamePrefix is added when the whole name starts with the whole query
+	scoreNamePrefix = 5
+	// minFuzzyTokenLength keeps short query tokens, which are mostly being typed, from matching by edit distance
+	minFuzzyTokenLength = 4
+
+	defaultSearchLimit = 10
+	maxSearchLimit     = 100
+)
+
+// searchIndex finds financial instruments by their security name. It is built with the dataset and never modified.
+type searchIndex struct {
+	tokens  []string            // the distinct tokens of all the names, sorted for prefix lookups
+	byToken map[string][]string // token to the sorted UUIDs of the names holding it
+	// token, and token with one rune deleted, to the tokens long enough to be one edit away from a fuzzy query token,
+	// so that a query token is only compared with the tokens sharing one of its variants
+	variants map[string][]string
+	names    map[string]string // UUID to the normalised name
+}
+
+type searchResult struct {
+	UUID  string
+	score int
+}
+
+func newSearchIndex(fis map[string]financialInstrument) searchIndex {
+	index := searchIndex{
+		byToken:  make(map[string][]string),
+		variants: make(map[string][]string),
+		names:    make(map[string]string, len(fis)),
+	}
+	for UUID, fi := range fis {
+		tokens := tokenize(fi.securityName)
+		if len(tokens) == 0 {
+			continue
+		}
+		index.names[UUID] = strings.Join(tokens, " ")
+		seen := make(map[string]bool, len(tokens))
+		for _, token := range tokens {
+			if seen[token] {
+				continue
+			}
+			seen[token] = true
+			if _, present := index.byToken[token]; !present {
+				index.tokens = append(index.tokens, token)
+				if len([]rune(token)) >= minFuzzyTokenLength-1 {
+					for _, variant := range editVariants(token) {
+						index.variants[variant] = append(index.variants[variant], token)
+					}
+				}
+			}
+			index.byToken[token] = append(index.byToken[token], UUID)
+		}
+	}
+	sort.Strings(index.tokens)
+	for _, UUIDs := range index.byToken {
+		sort.Strings(UUIDs)
+	}
+	return index
+}
+
+// tokenize lower-cases the text and splits it into words of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// search returns the UUIDs of the financial instruments whose name matches every token of the query,
+// best matches first. A query token matches a name token that is equal to it, starts with it,
+// or, for the longer query tokens, is one edit away from it.
+func (index searchIndex) search(query string, limit int) []string {
+	queryTokens := tokenize(query)
+	if len(queryTokens) == 0 {
+		return []string{}
+	}
+
+	var scores map[string]int
+	for _, queryToken := range queryTokens {
+		matches := index.match(queryToken)
+		if scores == nil {
+			scores = matches
+			continue
+		}
+		for UUID, score := range scores {
+			if match, present := matches[UUID]; present {
+				scores[UUID] = score + match
+			} else {
+				delete(scores, UUID)
+			}
+		}
+	}
+
+	normalisedQuery := strings.Join(queryTokens, " ")
+	results := make([]searchResult, 0, len(scores))
+	for UUID, score := range scores {
+		if strings.HasPrefix(index.names[UUID], normalisedQuery) {
+			score += scoreNamePrefix
+		}
+		results = append(results, searchResult{UUID: UUID, score: score})
+	}
+	// among equal scores, shorter names are closer to the query
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		nameI, nameJ := index.names[results[i].UUID], index.names[results[j].UUID]
+		if len(nameI) != len(nameJ) {
+			return len(nameI) < len(nameJ)
+		}
+		if nameI != nameJ {
+			return nameI < nameJ
+		}
+		return results[i].UUID < results[j].UUID
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	UUIDs := make([]string, 0, len(results))
+	for _, result := range results {
+		UUIDs = append(UUIDs, result.UUID)
+	}
+	return UUIDs
+}
+
+// match returns the best score of the query token against the names holding a matching token.
+func (index searchIndex) match(queryToken string) map[string]int {
+	matches := make(map[string]int)
+	add := func(token string, score int) {
+		for _, UUID := range index.byToken[token] {
+			if score > matches[UUID] {
+				matches[UUID] = score
+			}
+		}
+	}
+
+	for i := sort.SearchStrings(index.tokens, queryToken); i < len(index.tokens) && strings.HasPrefix(index.tokens[i], queryToken); i++ {
+		if index.tokens[i] == queryToken {
+			add(index.tokens[i], scoreExact)
+		} else {
+			add(index.tokens[i], scorePrefix)
+		}
+	}
+	if len([]rune(queryToken)) >= minFuzzyTokenLength {
+		// a token one edit away shares a variant with the query token: itself for a deletion,
+		// the query token for an insertion, or the same deletion for a substitution
+		compared := make(map[string]bool)
+		for _, variant := range editVariants(queryToken) {
+			for _, token := range index.variants[variant] {
+				if compared[token] {
+					continue
+				}
+				compared[token] = true
+				if !strings.HasPrefix(token, queryToken) && withinOneEdit(queryToken, token) {
+					add(token, scoreFuzzy)
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// editVariants returns the token and the distinct tokens made by deleting one of its runes.
+func editVariants(token string) []string {
+	runes := []rune(token)
+	variants := []string{token}
+	for i := range runes {
+		// deleting any rune of a run of equal runes gives the same variant
+		if i > 0 && runes[i] == runes[i-1] {
+			continue
+		}
+		variants = append(variants, string(runes[:i])+string(runes[i+1:]))
+	}
+	return variants
+}
+
+// withinOneEdit tells whether a single insertion, deletion or substitution of a rune turns a into b.
+func withinOneEdit(a string, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) > len(rb) {
+		ra, rb = rb, ra
+	}
+	if len(rb)-len(ra) > 1 {
+		return false
+	}
+	i := 0
+	for i < len(ra) && ra[i] == rb[i] {
+		i++
+	}
+	if len(ra) == len(rb) {
+		// substitution of the first differing rune
+		return i == len(ra) || string(ra[i+1:]) == string(rb[i+1:])
+	}
+	// insertion of the first differing rune of the longer one
+	return string(ra[i:]) == string(rb[i+1:])
+}
+
+// Search finds the financial instruments whose name matches the q query parameter, best matches first,
+// and returns at most limit of them, 10 by default and 100 at most.
+func (h *httpHandler) Search(w http.ResponseWriter, r *http.Request) {
+	ds := h.dataset(w)
+	if ds == nil {
+		return
+	}
+
+	q := r.URL.Query()
+	query := strings.TrimSpace(q.Get("q"))
+	if query == "" {
+		writeMessage(w, http.StatusBadRequest, "q must not be empty")
+		return
+	}
+	limit := defaultSearchLimit
+	if l := q.Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			writeMessage(w, http.StatusBadRequest, "limit must be an integer between 1 and "+strconv.Itoa(maxSearchLimit))
+			return
+		}
+	}
+
+	uppFIs := []uppFI{}
+	for _, id := range ds.Search(query, limit) {
+		if fi, present := ds.Read(id); present {
+			uppFIs = append(uppFIs, toUppFI(id, fi))
+		}
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	writeJSON(w, r, ds, uppFIs, cacheListings)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var searchFIs = map[string]financialInstrument{
+	"8404dbec-2423-322d-9afa-f92e553e53b6": {securityID: "GG9B0P-S", securityName: "Marks & Spencer Group Plc"},
+	"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": {securityID: "JBP7Z8-S", securityName: "Industrija Precizne Mehanike AD"},
+	"23dc5c8b-412d-352f-8b46-b312265086de": {securityID: "K7TPSX-S", securityName: "Ralph Martindale & Company Ltd"},
+	"11f5ccf1-e6bf-3ec6-abaf-6380009a6c4b": {securityID: "DCZBY8-S", securityName: "SAGA COMMUNICATIONS INC  CL A"},
+	"7d4fdd8b-3bad-3766-af4a-b26a7bc56f10": {securityID: "S10JZW-S", securityName: "Spencer Communications Ltd"},
+	"0c6842aa-e858-3053-b034-687e6db9578a": {securityID: "P1H0LD-S", securityName: "Spencers Holdings"},
+	"24d7f133-d30b-394f-970c-5a5e3ed66061": {securityID: "T4MPH1-S", securityName: ""},
+}
+
+func TestSearchIndex_Search(t *testing.T) {
+	index := newSearchIndex(searchFIs)
+
+	var testCases = []struct {
+		name     string
+		query    string
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "name prefix ranks first, then exact token before token prefix, case-insensitive",
+			query:    "SPENCER",
+			limit:    10,
+			expected: []string{"7d4fdd8b-3bad-3766-af4a-b26a7bc56f10", "0c6842aa-e858-3053-b034-687e6db9578a", "8404dbec-2423-322d-9afa-f92e553e53b6"},
+		},
+		{
+			name:     "prefix of the last token being typed",
+			query:    "marks & spen",
+			limit:    10,
+			expected: []string{"8404dbec-2423-322d-9afa-f92e553e53b6"},
+		},
+		{
+			name:     "every token must match, in any order",
+			query:    "communications spencer",
+			limit:    10,
+			expected: []string{"7d4fdd8b-3bad-3766-af4a-b26a7bc56f10"},
+		},
+		{
+			name:     "equal scores, shorter names first",
+			query:    "comm",
+			limit:    10,
+			expected: []string{"7d4fdd8b-3bad-3766-af4a-b26a7bc56f10", "11f5ccf1-e6bf-3ec6-abaf-6380009a6c4b"},
+		},
+		{
+			name:     "two edits away",
+			query:    "martindail",
+			limit:    10,
+			expected: []string{},
+		},
+		{
+			name:     "one edit away",
+			query:    "martindle",
+			limit:    10,
+			expected: []string{"23dc5c8b-412d-352f-8b46-b312265086de"},
+		},
+		{
+			name:     "one substitution away, shorter names first",
+			query:    "spenser",
+			limit:    10,
+			expected: []string{"8404dbec-2423-322d-9afa-f92e553e53b6", "7d4fdd8b-3bad-3766-af4a-b26a7bc56f10"},
+		},
+		{
+			name:     "one insertion away",
+			query:    "mechanike",
+			limit:    10,
+			expected: []string{"404c8329-3f8e-348e-ba32-cf3eb2c1ffed"},
+		},
+		{
+			name:     "short tokens are not fuzzy",
+			query:    "plx",
+			limit:    10,
+			expected: []string{},
+		},
+		{
+			name:     "limited results",
+			query:    "comm",
+			limit:    1,
+			expected: []string{"7d4fdd8b-3bad-3766-af4a-b26a7bc56f10"},
+		},
+		{
+			name:     "no token in the query",
+			query:    " & ",
+			limit:    10,
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, index.search(tc.query, tc.limit))
+		})
+	}
+}
+
+func TestEditVariants(t *testing.T) {
+	assert.Equal(t, []string{"ltd", "td", "ld", "lt"}, editVariants("ltd"))
+	assert.Equal(t, []string{"hill", "ill", "hll", "hil"}, editVariants("hill"))
+}
+
+func TestWithinOneEdit(t *testing.T) {
+	var testCases = []struct {
+		a, b     string
+		expected bool
+	}{
+		{"spencer", "spencer", true},
+		{"spenser", "spencer", true},
+		{"spencr", "spencer", true},
+		{"spencers", "spencer", true},
+		{"pencer", "spencer", true},
+		{"spnecer", "spencer", false},
+		{"spen", "spencer", false},
+		{"mehanike", "mechanike", true},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, withinOneEdit(tc.a, tc.b), "[%s] and [%s]", tc.a, tc.b)
+	}
+}
+
+func TestSearch_StatusCodes(t *testing.T) {
+	h := httpHandler{fiService: serving(&fiServiceImpl{}, "2017-08-10", searchFIs)}
+
+	var testCases = []struct {
+		query        string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			query:        "?q=industrija",
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"uuid":"404c8329-3f8e-348e-ba32-cf3eb2c1ffed","prefLabel":"Industrija Precizne Mehanike AD","alternativeIdentifiers":{"uuids":["404c8329-3f8e-348e-ba32-cf3eb2c1ffed"],"factsetIdentifier":"JBP7Z8-S","figiCode":""},"issuedBy":""}]` + "\n",
+		},
+		{query: "?q=unknown", expectedCode: http.StatusOK, expectedBody: "[]\n"},
+		{query: "?q=+", expectedCode: http.StatusBadRequest, expectedBody: `{"message":"q must not be empty"}` + "\n"},
+		{query: "?q=comm&limit=0", expectedCode: http.StatusBadRequest, expectedBody: `{"message":"limit must be an integer between 1 and 100"}` + "\n"},
+		{query: "?q=comm&limit=101", expectedCode: http.StatusBadRequest, expectedBody: `{"message":"limit must be an integer between 1 and 100"}` + "\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.Search(w, httptest.NewRequest("GET", "/transformers/financial-instruments/__search"+tc.query, nil))
+
+			require.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestSearch_NotInitialisedService_StatusServiceUnavailable(t *testing.T) {
+	h := httpHandler{fiService: &fiServiceImpl{}}
+	w := httptest.NewRecorder()
+
+	h.Search(w, httptest.NewRequest("GET", "/transformers/financial-instruments/__search?q=spencer", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -1,49 +1,467 @@
 package main
 
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
 type fiService interface {
-	Init()
-	Read(UUID string) (financialInstrument, bool)
-	IDs() []string
-	Count() int
+	Init() error
+	Reload() error
+	Dataset() *dataset
 	IsInitialised() bool
+	Status() loadStatus
+	Refresh() error
+	Pin(folder string) error
+	Unpin()
+	Folders() ([]string, error)
 	checkConnectivity() error
 }
 
+var (
+	errInvalidResourcesFolder  = errors.New("resources folder must be a date formatted as " + dateFormat)
+	errResourcesFolderNotFound = errors.New("resources folder does not exist")
+)
+
+// fiIndexes map the identifiers downstream systems hold to the UUIDs of the financial instruments,
+// and keep the UUIDs sorted so that listings are served in a stable order.
+// The FIGI of any listing of a financial instrument leads to it, unless it is the FIGI of another financial instrument.
+type fiIndexes struct {
+	sortedIDs   []string
+	byFIGI      map[string]string
+	byFactsetID map[string]string
+	byIssuer    map[string][]string
+}
+
+func newFIIndexes(fis map[string]financialInstrument) fiIndexes {
+	indexes := fiIndexes{
+		sortedIDs:   make([]string, 0, len(fis)),
+		byFIGI:      make(map[string]string),
+		byFactsetID: make(map[string]string),
+		byIssuer:    make(map[string][]string),
+	}
+	for UUID, fi := range fis {
+		indexes.sortedIDs = append(indexes.sortedIDs, UUID)
+		if fi.figiCode != "" {
+			indexes.byFIGI[fi.figiCode] = UUID
+		}
+		if fi.securityID != "" {
+			indexes.byFactsetID[fi.securityID] = UUID
+		}
+		if fi.orgID != "" {
+			indexes.byIssuer[fi.orgID] = append(indexes.byIssuer[fi.orgID], UUID)
+		}
+	}
+	sort.Strings(indexes.sortedIDs)
+	for _, UUIDs := range indexes.byIssuer {
+		sort.Strings(UUIDs)
+	}
+	// listing FIGIs are indexed in UUID order, so that a FIGI shared by several listings always leads to the same one
+	for _, UUID := range indexes.sortedIDs {
+		for _, l := range fis[UUID].listings {
+			if _, taken := indexes.byFIGI[l.figiCode]; l.figiCode != "" && !taken {
+				indexes.byFIGI[l.figiCode] = UUID
+			}
+		}
+	}
+	return indexes
+}
+
+// backoffConfig controls how Init retries a failed transformation: the wait doubles after every failed attempt
+// up to maxInterval, and Init gives up once the next attempt would start after the deadline.
+type backoffConfig struct {
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	deadline        time.Duration
+}
+
+type loadStatus struct {
+	ResourcesFolder string    `json:"resourcesFolder"`
+	DailyFolders    []string  `json:"dailyFolders,omitempty"`
+	PinnedFolder    string    `json:"pinnedFolder,omitempty"`
+	Attempts        int       `json:"attempts"`
+	LastSuccess     time.Time `json:"lastSuccess"`
+	LastFailure     time.Time `json:"lastFailure"`
+	LastError       string    `json:"lastError,omitempty"`
+}
+
+// fiServiceImpl serves the current dataset, which readers load without locking.
+// The mutex only guards the load status, the pinned folder and the financial instruments left to publish.
 type fiServiceImpl struct {
-	fit                  fiTransformer
-	config               s3Config
-	financialInstruments map[string]financialInstrument
+	sync.RWMutex
+	fit          fiTransformer
+	config       s3Config
+	backoff      backoffConfig
+	snapshots    *snapshotStore
+	publisher    *publisher
+	loading      sync.Mutex
+	publishing   sync.Mutex
+	current      atomic.Value
+	pinnedFolder string
+	status       loadStatus
+	// unpublished holds the UUIDs of the financial instruments changed or removed by a load but not published yet,
+	// and inFlight the ones being published
+	unpublished map[string]bool
+	inFlight    map[string]bool
 }
 
-func (fis *fiServiceImpl) Init() {
-	financialInstruments, err := fis.fit.Transform()
+func (fis *fiServiceImpl) Init() error {
+	start := time.Now()
+	interval := fis.backoff.initialInterval
+	for attempt := 1; ; attempt++ {
+		fis.setAttempts(attempt)
+		err := fis.initOnce()
+		if err == nil {
+			return nil
+		}
+
+		wait := withJitter(interval)
+		if time.Since(start)+wait > fis.backoff.deadline {
+			errorLogger.Printf("Giving up initialising FIs after [%d] attempts: [%v]", attempt, err)
+			return err
+		}
+		warnLogger.Printf("Attempt [%d] to initialise FIs failed: [%v]. Retrying in [%v]", attempt, err, wait)
+		time.Sleep(wait)
+
+		interval *= 2
+		if interval > fis.backoff.maxInterval {
+			interval = fis.backoff.maxInterval
+		}
+	}
+}
+
+// Reload loads the target dataset once, even if it is the one being served. Unlike Init, it does not retry.
+func (fis *fiServiceImpl) Reload() error {
+	return fis.initOnce()
+}
+
+func (fis *fiServiceImpl) initOnce() error {
+	folder, dailies, err := fis.targetDataset()
 	if err != nil {
-		errorLogger.Println(err)
-		return
+		fis.recordFailure(err)
+		return err
+	}
+	return fis.load(folder, dailies)
+}
+
+// withJitter returns a random duration between half and the whole of the given interval,
+// so that several instances failing at the same time do not retry in lockstep.
+func withJitter(interval time.Duration) time.Duration {
+	if interval <= 1 {
+		return interval
+	}
+	half := interval / 2
+	return half + time.Duration(rand.Int63n(int64(interval-half)))
+}
+
+// targetResourcesFolder returns the pinned folder if there is one, otherwise the folder named in the weekly index.
+func (fis *fiServiceImpl) targetResourcesFolder() (string, error) {
+	fis.RLock()
+	pinned := fis.pinnedFolder
+	fis.RUnlock()
+	if pinned != "" {
+		return pinned, nil
+	}
+	return fis.fit.findLatestResourcesFolder()
+}
+
+// targetDataset returns the target folder and the daily folders to apply on top of its weekly zip.
+func (fis *fiServiceImpl) targetDataset() (string, []string, error) {
+	folder, err := fis.targetResourcesFolder()
+	if err != nil {
+		return "", nil, err
+	}
+	dailies, err := fis.fit.findDailyFolders(folder)
+	if err != nil {
+		return "", nil, err
+	}
+	return folder, dailies, nil
+}
+
+// Refresh reloads the financial instruments if the target folder or its daily folders are different from the ones being served.
+func (fis *fiServiceImpl) Refresh() error {
+	folder, dailies, err := fis.targetDataset()
+	if err != nil {
+		fis.recordFailure(err)
+		return err
+	}
+	loadedFolder, loadedDailies := fis.loadedDataset()
+	if folder == loadedFolder && equalFolders(dailies, loadedDailies) {
+		infoLogger.Printf("Resources folder [%s] with daily folders %v is already loaded", folder, dailies)
+		fis.publishPending()
+		return nil
 	}
-	fis.financialInstruments = financialInstruments
+	infoLogger.Printf("Resources folder changed to [%s] with daily folders %v. Reloading FIs.", folder, dailies)
+	return fis.load(folder, dailies)
+}
+
+func equalFolders(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Pin makes the service serve the given dated folder instead of following the weekly index.
+// The folder is loaded on the next Refresh.
+func (fis *fiServiceImpl) Pin(folder string) error {
+	if !isValidResourcesFolder(folder) {
+		return errInvalidResourcesFolder
+	}
+	folders, err := fis.Folders()
+	if err != nil {
+		return err
+	}
+	i := sort.SearchStrings(folders, folder)
+	if i == len(folders) || folders[i] != folder {
+		return errResourcesFolderNotFound
+	}
+
+	fis.Lock()
+	defer fis.Unlock()
+	fis.pinnedFolder = folder
+	infoLogger.Printf("Resources folder pinned to [%s]", folder)
+	return nil
+}
+
+// Unpin makes the service follow the weekly index again from the next Refresh.
+func (fis *fiServiceImpl) Unpin() {
+	fis.Lock()
+	defer fis.Unlock()
+	fis.pinnedFolder = ""
+	infoLogger.Println("Resources folder unpinned")
 }
 
-func (fis *fiServiceImpl) Read(UUID string) (financialInstrument, bool) {
-	fi, present := fis.financialInstruments[UUID]
-	return fi, present
+func (fis *fiServiceImpl) Folders() ([]string, error) {
+	return fis.fit.listResourcesFolders()
 }
 
-func (fis *fiServiceImpl) IDs() []string {
-	var UUIDs = []string{}
-	for UUID := range fis.financialInstruments {
+func (fis *fiServiceImpl) refreshPeriodically(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := fis.Refresh(); err != nil {
+				errorLogger.Printf("Could not refresh FIs: [%v]", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// load transforms the given folder and swaps the result in, so readers are served from the previous dataset until the new one is complete.
+// The changed financial instruments are then published, once the next load is free to start.
+func (fis *fiServiceImpl) load(folder string, dailies []string) error {
+	if err := fis.transformAndSwap(folder, dailies); err != nil {
+		return err
+	}
+	fis.publishPending()
+	return nil
+}
+
+func (fis *fiServiceImpl) transformAndSwap(folder string, dailies []string) error {
+	fis.loading.Lock()
+	defer fis.loading.Unlock()
+
+	start := time.Now()
+	result, err := fis.fit.Transform(folder, dailies)
+	observeTransform(time.Since(start), result, err)
+	if err != nil {
+		fis.recordFailure(err)
+		return err
+	}
+	loaded := time.Now()
+	changes := fis.diff(folder, result.financialInstruments)
+	infoLogger.Printf("Changes from resources folder [%s] to [%s]: [%d] added, [%d] removed, [%d] modified",
+		changes.FromFolder, folder, len(changes.Added), len(changes.Removed), len(changes.Modified))
+	fis.swap(newDataset(folder, loaded, result, changes))
+	if fis.publisher != nil {
+		changed := changes.changedIDs()
+		for _, UUID := range changes.Removed {
+			changed[UUID] = true
+		}
+		fis.addUnpublished(changed)
+	}
+
+	if fis.snapshots != nil {
+		if err := fis.snapshots.save(folder, loaded, result, fis.pendingPublish()); err != nil {
+			warnLogger.Printf("Could not save snapshot of resources folder [%s]: [%v]", folder, err)
+		}
+	}
+	return nil
+}
+
+func (fis *fiServiceImpl) addUnpublished(UUIDs map[string]bool) {
+	fis.Lock()
+	defer fis.Unlock()
+	if fis.unpublished == nil {
+		fis.unpublished = make(map[string]bool, len(UUIDs))
+	}
+	for UUID := range UUIDs {
+		fis.unpublished[UUID] = true
+	}
+}
+
+// pendingPublish returns the sorted UUIDs of the financial instruments not published yet, including the ones being published.
+func (fis *fiServiceImpl) pendingPublish() []string {
+	fis.RLock()
+	defer fis.RUnlock()
+	var UUIDs []string
+	for UUID := range fis.unpublished {
 		UUIDs = append(UUIDs, UUID)
 	}
+	for UUID := range fis.inFlight {
+		if !fis.unpublished[UUID] {
+			UUIDs = append(UUIDs, UUID)
+		}
+	}
+	sort.Strings(UUIDs)
 	return UUIDs
 }
 
-func (fis *fiServiceImpl) Count() int {
-	count := len(fis.financialInstruments)
-	return count
+// publishPending publishes the financial instruments changed by the loads since the last successful publish,
+// as they are in the dataset being served, and the removal of the ones it no longer holds. When the publish fails
+// they are kept for the next attempt, which comes with the next refresh.
+func (fis *fiServiceImpl) publishPending() {
+	if fis.publisher == nil {
+		return
+	}
+	fis.publishing.Lock()
+	defer fis.publishing.Unlock()
+
+	fis.Lock()
+	pending := fis.unpublished
+	fis.unpublished = nil
+	fis.inFlight = pending
+	fis.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	ds := fis.Dataset()
+	changed := make(map[string]financialInstrument, len(pending))
+	var removed []string
+	for UUID := range pending {
+		if fi, present := ds.Read(UUID); present {
+			changed[UUID] = fi
+		} else {
+			removed = append(removed, UUID)
+		}
+	}
+	if err := fis.publisher.publish(changed, removed); err != nil {
+		errorLogger.Printf("Could not publish [%d] FIs and [%d] removals of resources folder [%s], retrying with the next refresh: [%v]",
+			len(changed), len(removed), ds.resourcesFolder, err)
+		fis.addUnpublished(pending)
+	}
+	fis.Lock()
+	fis.inFlight = nil
+	fis.Unlock()
+}
+
+// swap makes the given dataset the one being served, and records it as the last successful load.
+func (fis *fiServiceImpl) swap(ds *dataset) {
+	fis.current.Store(ds)
+
+	fis.Lock()
+	defer fis.Unlock()
+	fis.status.ResourcesFolder = ds.resourcesFolder
+	fis.status.DailyFolders = ds.dailyFolders
+	fis.status.LastSuccess = ds.loaded
+	instrumentsLoaded.Set(float64(ds.Count()))
+	infoLogger.Printf("Serving dataset version [%s] of resources folder [%s]", ds.version, ds.resourcesFolder)
+}
+
+// Dataset returns the dataset being served, nil until one has been loaded.
+func (fis *fiServiceImpl) Dataset() *dataset {
+	ds, _ := fis.current.Load().(*dataset)
+	return ds
+}
+
+// diff compares the dataset being served with the one about to replace it. On the first load everything is added.
+func (fis *fiServiceImpl) diff(folder string, new map[string]financialInstrument) datasetChanges {
+	var old map[string]financialInstrument
+	fromFolder := ""
+	if ds := fis.Dataset(); ds != nil {
+		old = ds.financialInstruments
+		fromFolder = ds.resourcesFolder
+	}
+	changes := diffFIs(old, new)
+	changes.FromFolder = fromFolder
+	changes.ToFolder = folder
+	changes.Computed = time.Now()
+	return changes
+}
+
+// restoreSnapshot serves the last persisted dataset, if any, until a fresh one is loaded. It reports whether a snapshot was restored.
+func (fis *fiServiceImpl) restoreSnapshot() bool {
+	if fis.snapshots == nil {
+		return false
+	}
+	snap, result, err := fis.snapshots.load()
+	if os.IsNotExist(err) {
+		infoLogger.Println("No snapshot to restore")
+		return false
+	}
+	if err != nil {
+		warnLogger.Printf("Could not restore snapshot: [%v]", err)
+		return false
+	}
+	fis.swap(newDataset(snap.ResourcesFolder, snap.Created, result, datasetChanges{}))
+	if fis.publisher != nil && len(snap.Unpublished) > 0 {
+		unpublished := make(map[string]bool, len(snap.Unpublished))
+		for _, UUID := range snap.Unpublished {
+			unpublished[UUID] = true
+		}
+		fis.addUnpublished(unpublished)
+		infoLogger.Printf("Restored [%d] FIs not published before the restart", len(unpublished))
+	}
+	infoLogger.Printf("Restored snapshot of resources folder [%s] created at [%v]. Nr of FIs: [%d]", snap.ResourcesFolder, snap.Created, len(result.financialInstruments))
+	return true
+}
+
+func (fis *fiServiceImpl) recordFailure(err error) {
+	fis.Lock()
+	defer fis.Unlock()
+	fis.status.LastFailure = time.Now()
+	fis.status.LastError = err.Error()
+}
+
+func (fis *fiServiceImpl) setAttempts(attempts int) {
+	fis.Lock()
+	defer fis.Unlock()
+	fis.status.Attempts = attempts
+}
+
+func (fis *fiServiceImpl) Status() loadStatus {
+	fis.RLock()
+	defer fis.RUnlock()
+	status := fis.status
+	status.PinnedFolder = fis.pinnedFolder
+	return status
+}
+
+func (fis *fiServiceImpl) loadedDataset() (string, []string) {
+	ds := fis.Dataset()
+	if ds == nil {
+		return "", nil
+	}
+	return ds.resourcesFolder, ds.dailyFolders
 }
 
 func (fis *fiServiceImpl) IsInitialised() bool {
-	return fis.financialInstruments != nil
+	return fis.Dataset() != nil
 }
 
 func (fis *fiServiceImpl) checkConnectivity() error {
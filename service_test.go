@@ -1,23 +1,59 @@
 package main
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type transformerMock struct {
-	mockTransform             func() (map[string]financialInstrument, error)
-	mockCheckConnectivityToS3 func() error
+	mockTransform                 func() (map[string]financialInstrument, error)
+	mockFindLatestResourcesFolder func() (string, error)
+	mockListResourcesFolders      func() ([]string, error)
+	mockCheckConnectivityToS3     func() error
+	mockFindDailyFolders          func(folder string) ([]string, error)
+}
+
+func (tm *transformerMock) Transform(folder string, dailies []string) (transformResult, error) {
+	fis, err := tm.mockTransform()
+	return transformResult{financialInstruments: fis, report: transformReport{ResourcesFolder: folder, DailyFolders: dailies}}, err
+}
+
+func (tm *transformerMock) findDailyFolders(folder string) ([]string, error) {
+	if tm.mockFindDailyFolders == nil {
+		return nil, nil
+	}
+	return tm.mockFindDailyFolders(folder)
+}
+
+func (tm *transformerMock) findLatestResourcesFolder() (string, error) {
+	return tm.mockFindLatestResourcesFolder()
 }
 
-func (tm *transformerMock) Transform() (map[string]financialInstrument, error) {
-	return tm.mockTransform()
+func (tm *transformerMock) listResourcesFolders() ([]string, error) {
+	return tm.mockListResourcesFolders()
 }
 
 func (tm *transformerMock) checkConnectivityToS3() error {
 	return tm.mockCheckConnectivityToS3()
 }
 
+// serving makes the service serve the given financial instruments, as if they had been loaded from the given folder.
+// A nil map leaves the service without a dataset, as before its first load.
+func serving(fis *fiServiceImpl, folder string, m map[string]financialInstrument) *fiServiceImpl {
+	if m != nil {
+		fis.swap(newDataset(folder, time.Now(), transformResult{financialInstruments: m}, datasetChanges{}))
+	}
+	return fis
+}
+
 func TestFiServiceImpl_Read(t *testing.T) {
 	UUID := "7d4fdd8b-3bad-3766-af4a-b26a7bc56f10"
 
@@ -28,13 +64,11 @@ func TestFiServiceImpl_Read(t *testing.T) {
 		orgID:        "6745b841-6f2f-3741-bf2f-80d13ec68bdd",
 	}
 
-	fis := fiServiceImpl{
-		financialInstruments: map[string]financialInstrument{
-			UUID: expected,
-		},
-	}
+	fis := serving(&fiServiceImpl{}, "2017-08-10", map[string]financialInstrument{
+		UUID: expected,
+	})
 
-	fi, present := fis.Read(UUID)
+	fi, present := fis.Dataset().Read(UUID)
 
 	if present == false {
 		t.Errorf("expecting that financial instrument [%v] to be found", expected)
@@ -57,13 +91,11 @@ func TestFiServiceImpl_Read_NotExistingValue(t *testing.T) {
 		orgID:        "6745b841-6f2f-3741-bf2f-80d13ec68bdd",
 	}
 
-	fis := fiServiceImpl{
-		financialInstruments: map[string]financialInstrument{
-			UUID: expected,
-		},
-	}
+	fis := serving(&fiServiceImpl{}, "2017-08-10", map[string]financialInstrument{
+		UUID: expected,
+	})
 
-	_, present := fis.Read(searchedUUID)
+	_, present := fis.Dataset().Read(searchedUUID)
 
 	if present != false {
 		t.Errorf("Expecting that financial instrument [%v] to be not found", expected)
@@ -75,7 +107,7 @@ func TestFiServiceImpl_Read_NotInitialisedService(t *testing.T) {
 
 	fis := fiServiceImpl{}
 
-	_, present := fis.Read(searchedUUID)
+	_, present := fis.Dataset().Read(searchedUUID)
 
 	if present != false {
 		t.Error("Not expecting to find any financial instrument")
@@ -93,14 +125,12 @@ func TestFiServiceImpl_Count(t *testing.T) {
 		orgID:        "6745b841-6f2f-3741-bf2f-80d13ec68bdd",
 	}
 
-	fis := fiServiceImpl{
-		financialInstruments: map[string]financialInstrument{
-			UUID1: expected,
-			UUID2: expected,
-		},
-	}
+	fis := serving(&fiServiceImpl{}, "2017-08-10", map[string]financialInstrument{
+		UUID1: expected,
+		UUID2: expected,
+	})
 
-	count := fis.Count()
+	count := fis.Dataset().Count()
 
 	if count != 2 {
 		t.Errorf("Expecting to found 2 financial instruments, but found [%d]", count)
@@ -110,7 +140,7 @@ func TestFiServiceImpl_Count(t *testing.T) {
 func TestFiServiceImpl_Count_NotInitialisedService(t *testing.T) {
 	fis := fiServiceImpl{}
 
-	count := fis.Count()
+	count := fis.Dataset().Count()
 
 	if count != 0 {
 		t.Errorf("Expecting to found 0 financial instruments, but found [%d]", count)
@@ -127,11 +157,9 @@ func TestFiServiceImpl_IsInitialised(t *testing.T) {
 		orgID:        "6745b841-6f2f-3741-bf2f-80d13ec68bdd",
 	}
 
-	fis := fiServiceImpl{
-		financialInstruments: map[string]financialInstrument{
-			UUID: expected,
-		},
-	}
+	fis := serving(&fiServiceImpl{}, "2017-08-10", map[string]financialInstrument{
+		UUID: expected,
+	})
 
 	initialised := fis.IsInitialised()
 
@@ -161,20 +189,18 @@ func TestFiServiceImpl_IDs(t *testing.T) {
 		orgID:        "6745b841-6f2f-3741-bf2f-80d13ec68bdd",
 	}
 
-	fis := fiServiceImpl{
-		financialInstruments: map[string]financialInstrument{
-			UUID1: fi,
-			UUID2: fi,
-		},
+	m := map[string]financialInstrument{
+		UUID1: fi,
+		UUID2: fi,
 	}
+	fis := serving(&fiServiceImpl{}, "2017-08-10", m)
 
-	expected1 := []string{"7d4fdd8b-3bad-3766-af4a-b26a7bc56f10", "24d7f133-d30b-394f-970c-5a5e3ed66061"}
-	expected2 := []string{"24d7f133-d30b-394f-970c-5a5e3ed66061", "7d4fdd8b-3bad-3766-af4a-b26a7bc56f10"}
+	expected := []string{"24d7f133-d30b-394f-970c-5a5e3ed66061", "7d4fdd8b-3bad-3766-af4a-b26a7bc56f10"}
 
-	IDs := fis.IDs()
+	IDs := fis.Dataset().IDs()
 
-	if !reflect.DeepEqual(IDs, expected1) && !reflect.DeepEqual(IDs, expected2) {
-		t.Errorf("Expected: [%v] or [%v]. Actual: [%v]", expected1, expected2, IDs)
+	if !reflect.DeepEqual(IDs, expected) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expected, IDs)
 	}
 }
 
@@ -182,7 +208,7 @@ func TestFiServiceImpl_IDs_NotInitialisedService(t *testing.T) {
 	fis := fiServiceImpl{}
 	expected := []string{}
 
-	IDs := fis.IDs()
+	IDs := fis.Dataset().IDs()
 
 	if !reflect.DeepEqual(IDs, expected) {
 		t.Errorf("Expected: [%v]. Actual: [%v]", expected, IDs)
@@ -209,6 +235,10 @@ func TestFiServiceImpl_Init(t *testing.T) {
 		},
 	}
 
+	tm.mockFindLatestResourcesFolder = func() (string, error) {
+		return "2017-08-10", nil
+	}
+
 	expected := map[string]financialInstrument{
 		UUID1: fi,
 		UUID2: fi,
@@ -217,7 +247,739 @@ func TestFiServiceImpl_Init(t *testing.T) {
 	fis := fiServiceImpl{fit: tm}
 	fis.Init()
 
-	if !reflect.DeepEqual(fis.financialInstruments, expected) {
-		t.Errorf("Expected: [%v]. Actual: [%v]", expected, fis.financialInstruments)
+	if !reflect.DeepEqual(fis.Dataset().financialInstruments, expected) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expected, fis.Dataset().financialInstruments)
+	}
+	if fis.Dataset().resourcesFolder != "2017-08-10" {
+		t.Errorf("Expected loaded folder: [2017-08-10]. Actual: [%s]", fis.Dataset().resourcesFolder)
+	}
+}
+
+func TestFiServiceImpl_Refresh(t *testing.T) {
+	fi := financialInstrument{
+		securityID:   "S10JZW-S-CA",
+		securityName: "QUIZAM MEDIA CORP COM",
+		figiCode:     "BBG000D9Y7X",
+		orgID:        "6745b841-6f2f-3741-bf2f-80d13ec68bdd",
+	}
+	old := map[string]financialInstrument{"7d4fdd8b-3bad-3766-af4a-b26a7bc56f10": fi}
+	reloaded := map[string]financialInstrument{"24d7f133-d30b-394f-970c-5a5e3ed66061": fi}
+
+	var testCases = []struct {
+		name           string
+		latestFolder   string
+		transformCalls int
+		expected       map[string]financialInstrument
+	}{
+		{
+			name:           "same folder is not reloaded",
+			latestFolder:   "2017-08-10",
+			transformCalls: 0,
+			expected:       old,
+		},
+		{
+			name:           "new folder is reloaded",
+			latestFolder:   "2017-08-17",
+			transformCalls: 1,
+			expected:       reloaded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			tm := &transformerMock{
+				mockFindLatestResourcesFolder: func() (string, error) {
+					return tc.latestFolder, nil
+				},
+				mockTransform: func() (map[string]financialInstrument, error) {
+					calls++
+					return reloaded, nil
+				},
+			}
+			fis := serving(&fiServiceImpl{fit: tm}, "2017-08-10", old)
+
+			err := fis.Refresh()
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.transformCalls, calls)
+			assert.Equal(t, tc.expected, fis.Dataset().financialInstruments)
+			assert.Equal(t, tc.latestFolder, fis.Dataset().resourcesFolder)
+		})
+	}
+}
+
+func TestFiServiceImpl_Refresh_DatasetHeldByAReaderIsNotModified(t *testing.T) {
+	old := map[string]financialInstrument{"7d4fdd8b-3bad-3766-af4a-b26a7bc56f10": {securityID: "S10JZW-S-CA"}}
+	reloaded := map[string]financialInstrument{"24d7f133-d30b-394f-970c-5a5e3ed66061": {securityID: "T4MPH1-S"}}
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return "2017-08-17", nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			return reloaded, nil
+		},
+	}
+	fis := serving(&fiServiceImpl{fit: tm}, "2017-08-10", old)
+	held := fis.Dataset()
+	version := held.Version()
+
+	require.NoError(t, fis.Refresh())
+
+	assert.Equal(t, version, held.Version())
+	assert.Equal(t, "2017-08-10", held.resourcesFolder)
+	assert.Equal(t, []string{"7d4fdd8b-3bad-3766-af4a-b26a7bc56f10"}, held.IDs())
+	assert.NotEqual(t, version, fis.Dataset().Version())
+	assert.Equal(t, []string{"24d7f133-d30b-394f-970c-5a5e3ed66061"}, fis.Dataset().IDs())
+}
+
+func TestFiServiceImpl_Refresh_ConcurrentReadersSeeOneVersion(t *testing.T) {
+	first := map[string]financialInstrument{"7d4fdd8b-3bad-3766-af4a-b26a7bc56f10": {securityID: "S10JZW-S-CA"}}
+	second := map[string]financialInstrument{"24d7f133-d30b-394f-970c-5a5e3ed66061": {securityID: "T4MPH1-S"}}
+	folder := "2017-08-10"
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return folder, nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			if folder == "2017-08-10" {
+				return first, nil
+			}
+			return second, nil
+		},
+	}
+	fis := &fiServiceImpl{fit: tm}
+	require.NoError(t, fis.Init())
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				done <- nil
+				return
+			default:
+			}
+			ds := fis.Dataset()
+			for _, UUID := range ds.IDs() {
+				if _, present := ds.Read(UUID); !present {
+					done <- errors.New("FI " + UUID + " of version " + ds.Version() + " is missing")
+					return
+				}
+			}
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			folder = "2017-08-17"
+		} else {
+			folder = "2017-08-10"
+		}
+		require.NoError(t, fis.Refresh())
+	}
+	close(stop)
+	assert.NoError(t, <-done)
+}
+
+func TestFiServiceImpl_Refresh_NewDailyFolderIsLoaded(t *testing.T) {
+	dailies := []string{"2017-08-14"}
+	calls := 0
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return "2017-08-10", nil
+		},
+		mockFindDailyFolders: func(folder string) ([]string, error) {
+			return dailies, nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			calls++
+			return map[string]financialInstrument{}, nil
+		},
+	}
+	fis := serving(&fiServiceImpl{fit: tm}, "2017-08-10", map[string]financialInstrument{})
+
+	assert.NoError(t, fis.Refresh())
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, []string{"2017-08-14"}, fis.Status().DailyFolders)
+
+	assert.NoError(t, fis.Refresh())
+	assert.Equal(t, 1, calls, "the same daily folders must not be reloaded")
+
+	dailies = []string{"2017-08-14", "2017-08-15"}
+	assert.NoError(t, fis.Refresh())
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, []string{"2017-08-14", "2017-08-15"}, fis.Status().DailyFolders)
+}
+
+func TestFiServiceImpl_Refresh_TransformFails_OldFIsAreKept(t *testing.T) {
+	old := map[string]financialInstrument{"7d4fdd8b-3bad-3766-af4a-b26a7bc56f10": {}}
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return "2017-08-17", nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			return nil, errors.New("broken zip")
+		},
+	}
+	fis := serving(&fiServiceImpl{fit: tm}, "2017-08-10", old)
+
+	err := fis.Refresh()
+
+	assert.Error(t, err)
+	assert.Equal(t, old, fis.Dataset().financialInstruments)
+	assert.Equal(t, "2017-08-10", fis.Dataset().resourcesFolder)
+}
+
+func TestFiServiceImpl_Refresh_TargetDatasetUnknown_FailureIsRecorded(t *testing.T) {
+	var testCases = []struct {
+		name string
+		tm   *transformerMock
+	}{
+		{
+			name: "weekly index unreadable",
+			tm: &transformerMock{
+				mockFindLatestResourcesFolder: func() (string, error) {
+					return "", errors.New("S3 is not reachable")
+				},
+			},
+		},
+		{
+			name: "daily folders not listed",
+			tm: &transformerMock{
+				mockFindLatestResourcesFolder: func() (string, error) {
+					return "2017-08-17", nil
+				},
+				mockFindDailyFolders: func(folder string) ([]string, error) {
+					return nil, errors.New("S3 is not reachable")
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fis := serving(&fiServiceImpl{fit: tc.tm}, "2017-08-10", map[string]financialInstrument{"7d4fdd8b-3bad-3766-af4a-b26a7bc56f10": {}})
+
+			err := fis.Refresh()
+
+			assert.EqualError(t, err, "S3 is not reachable")
+			status := fis.Status()
+			assert.Equal(t, "S3 is not reachable", status.LastError)
+			assert.True(t, status.LastFailure.After(status.LastSuccess))
+		})
+	}
+}
+
+func TestFiServiceImpl_Refresh_TransformedFIsArePublished(t *testing.T) {
+	reloaded := map[string]financialInstrument{"24d7f133-d30b-394f-970c-5a5e3ed66061": {securityID: "S10JZW-S-CA"}}
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return "2017-08-17", nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			return reloaded, nil
+		},
+	}
+	broker := &inMemoryBroker{}
+	fis := &fiServiceImpl{fit: tm, publisher: newPublisher(broker)}
+
+	err := fis.Refresh()
+
+	assert.NoError(t, err)
+	require.Len(t, broker.messages, 1)
+	assert.Equal(t, "24d7f133-d30b-394f-970c-5a5e3ed66061", broker.messages[0].key)
+}
+
+func TestFiServiceImpl_Refresh_OnlyChangesArePublished(t *testing.T) {
+	unchanged := financialInstrument{securityID: "JBP7Z8-S", securityName: "Industrija Precizne Mehanike AD"}
+	old := map[string]financialInstrument{
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": unchanged,
+		"8404dbec-2423-322d-9afa-f92e553e53b6": {securityID: "GG9B0P-S", securityName: "Marks and Spencer Group Plc"},
+	}
+	reloaded := map[string]financialInstrument{
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": unchanged,
+		"8404dbec-2423-322d-9afa-f92e553e53b6": {securityID: "GG9B0P-S", securityName: "Marks & Spencer Group Plc"},
+	}
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return "2017-08-17", nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			return reloaded, nil
+		},
+	}
+	broker := &inMemoryBroker{}
+	fis := serving(&fiServiceImpl{fit: tm, publisher: newPublisher(broker)}, "2017-08-10", old)
+
+	err := fis.Refresh()
+
+	assert.NoError(t, err)
+	require.Len(t, broker.messages, 1)
+	assert.Equal(t, "8404dbec-2423-322d-9afa-f92e553e53b6", broker.messages[0].key)
+
+	changes := fis.Dataset().Changes()
+	assert.Equal(t, "2017-08-10", changes.FromFolder)
+	assert.Equal(t, "2017-08-17", changes.ToFolder)
+	assert.Empty(t, changes.Added)
+	assert.Empty(t, changes.Removed)
+	assert.Equal(t, []fiChange{
+		{
+			UUID:   "8404dbec-2423-322d-9afa-f92e553e53b6",
+			Fields: []fieldChange{{Field: "prefLabel", Old: "Marks and Spencer Group Plc", New: "Marks & Spencer Group Plc"}},
+		},
+	}, changes.Modified)
+}
+
+func TestFiServiceImpl_Refresh_TransformFails_NothingIsPublished(t *testing.T) {
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return "2017-08-17", nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			return nil, errors.New("broken zip")
+		},
+	}
+	broker := &inMemoryBroker{}
+	fis := &fiServiceImpl{fit: tm, publisher: newPublisher(broker)}
+
+	err := fis.Refresh()
+
+	assert.Error(t, err)
+	assert.Empty(t, broker.messages)
+}
+
+func TestFiServiceImpl_Refresh_PublishFails_ChangesArePublishedWithTheNextRefresh(t *testing.T) {
+	folder := "2017-08-17"
+	datasets := map[string]map[string]financialInstrument{
+		"2017-08-17": {"24d7f133-d30b-394f-970c-5a5e3ed66061": {securityID: "S10JZW-S-CA"}},
+		"2017-08-24": {
+			"24d7f133-d30b-394f-970c-5a5e3ed66061": {securityID: "S10JZW-S-CA"},
+			"8404dbec-2423-322d-9afa-f92e553e53b6": {securityID: "GG9B0P-S"},
+		},
+	}
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return folder, nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			return datasets[folder], nil
+		},
+	}
+	broker := &inMemoryBroker{err: errors.New("kafka is not reachable")}
+	fis := &fiServiceImpl{fit: tm, publisher: newPublisher(broker)}
+
+	require.NoError(t, fis.Refresh())
+	assert.Empty(t, broker.messages)
+	assert.Equal(t, folder, fis.Dataset().resourcesFolder)
+
+	// the folder has not changed, but the changes it brought are still to publish
+	broker.err = nil
+	require.NoError(t, fis.Refresh())
+	require.Len(t, broker.messages, 1)
+	assert.Equal(t, "24d7f133-d30b-394f-970c-5a5e3ed66061", broker.messages[0].key)
+
+	// published changes are not published again
+	folder = "2017-08-24"
+	require.NoError(t, fis.Refresh())
+	require.Len(t, broker.messages, 2)
+	assert.Equal(t, "8404dbec-2423-322d-9afa-f92e553e53b6", broker.messages[1].key)
+}
+
+func TestFiServiceImpl_Refresh_PublishFails_ChangesAreKeptAcrossReloads(t *testing.T) {
+	folder := "2017-08-17"
+	datasets := map[string]map[string]financialInstrument{
+		"2017-08-17": {"24d7f133-d30b-394f-970c-5a5e3ed66061": {securityID: "S10JZW-S-CA"}},
+		"2017-08-24": {
+			"24d7f133-d30b-394f-970c-5a5e3ed66061": {securityID: "S10JZW-S-CA"},
+			"8404dbec-2423-322d-9afa-f92e553e53b6": {securityID: "GG9B0P-S"},
+		},
+	}
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return folder, nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			return datasets[folder], nil
+		},
+	}
+	broker := &inMemoryBroker{err: errors.New("kafka is not reachable")}
+	fis := &fiServiceImpl{fit: tm, publisher: newPublisher(broker)}
+	require.NoError(t, fis.Refresh())
+
+	broker.err = nil
+	folder = "2017-08-24"
+	require.NoError(t, fis.Refresh())
+
+	require.Len(t, broker.messages, 2)
+	assert.Equal(t, "24d7f133-d30b-394f-970c-5a5e3ed66061", broker.messages[0].key)
+	assert.Equal(t, "8404dbec-2423-322d-9afa-f92e553e53b6", broker.messages[1].key)
+}
+
+func TestFiServiceImpl_Refresh_RemovalsArePublished(t *testing.T) {
+	old := map[string]financialInstrument{
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": {securityID: "JBP7Z8-S"},
+		"8404dbec-2423-322d-9afa-f92e553e53b6": {securityID: "GG9B0P-S"},
+	}
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return "2017-08-17", nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			return map[string]financialInstrument{"8404dbec-2423-322d-9afa-f92e553e53b6": {securityID: "GG9B0P-S"}}, nil
+		},
+	}
+	broker := &inMemoryBroker{}
+	fis := serving(&fiServiceImpl{fit: tm, publisher: newPublisher(broker)}, "2017-08-10", old)
+
+	require.NoError(t, fis.Refresh())
+
+	require.Len(t, broker.messages, 1)
+	assert.Equal(t, "404c8329-3f8e-348e-ba32-cf3eb2c1ffed", broker.messages[0].key)
+	assert.Empty(t, broker.messages[0].message.Body)
+}
+
+func TestFiServiceImpl_PublishFails_ChangesArePublishedAfterRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fis_test_snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := newSnapshotStore(filepath.Join(dir, "snapshot.json"))
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return "2017-08-17", nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			return map[string]financialInstrument{"24d7f133-d30b-394f-970c-5a5e3ed66061": {securityID: "S10JZW-S-CA"}}, nil
+		},
+	}
+	fis := &fiServiceImpl{fit: tm, snapshots: store, publisher: newPublisher(&inMemoryBroker{err: errors.New("kafka is not reachable")})}
+	require.NoError(t, fis.Refresh())
+
+	broker := &inMemoryBroker{}
+	restarted := &fiServiceImpl{fit: tm, snapshots: store, publisher: newPublisher(broker)}
+	require.True(t, restarted.restoreSnapshot())
+	require.NoError(t, restarted.Refresh())
+
+	require.Len(t, broker.messages, 1)
+	assert.Equal(t, "24d7f133-d30b-394f-970c-5a5e3ed66061", broker.messages[0].key)
+}
+
+// blockingBroker holds every send until it is released.
+type blockingBroker struct {
+	sending chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBroker) SendMessages(msgs []keyedMessage) error {
+	b.sending <- struct{}{}
+	<-b.release
+	return nil
+}
+
+func TestFiServiceImpl_Refresh_PublishInProgress_NextDatasetIsLoaded(t *testing.T) {
+	folder := "2017-08-17"
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return folder, nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			return map[string]financialInstrument{"24d7f133-d30b-394f-970c-5a5e3ed66061": {securityName: folder}}, nil
+		},
+	}
+	broker := &blockingBroker{sending: make(chan struct{}), release: make(chan struct{})}
+	fis := &fiServiceImpl{fit: tm, publisher: newPublisher(broker)}
+	firstDone := make(chan error)
+	go func() { firstDone <- fis.Refresh() }()
+	<-broker.sending
+
+	folder = "2017-08-24"
+	secondDone := make(chan error)
+	go func() { secondDone <- fis.Refresh() }()
+	for i := 0; i < 100 && fis.Dataset().resourcesFolder != "2017-08-24"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, "2017-08-24", fis.Dataset().resourcesFolder, "Expected the load not to wait for the publish")
+
+	close(broker.release)
+	go func() {
+		for range broker.sending {
+		}
+	}()
+	assert.NoError(t, <-firstDone)
+	assert.NoError(t, <-secondDone)
+	close(broker.sending)
+}
+
+func TestFiServiceImpl_Init_RetriesUntilTransformSucceeds(t *testing.T) {
+	attempts := 0
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return "2017-08-10", nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			attempts++
+			if attempts < 3 {
+				return nil, errors.New("S3 is not reachable")
+			}
+			return map[string]financialInstrument{"foo": {}}, nil
+		},
+	}
+	fis := fiServiceImpl{
+		fit:     tm,
+		backoff: backoffConfig{initialInterval: time.Millisecond, maxInterval: 2 * time.Millisecond, deadline: time.Second},
+	}
+
+	err := fis.Init()
+
+	assert.NoError(t, err)
+	assert.True(t, fis.IsInitialised())
+	status := fis.Status()
+	assert.Equal(t, 3, status.Attempts)
+	assert.Equal(t, "2017-08-10", status.ResourcesFolder)
+	assert.Equal(t, "S3 is not reachable", status.LastError)
+	assert.True(t, status.LastSuccess.After(status.LastFailure))
+}
+
+func TestFiServiceImpl_Init_GivesUpAfterDeadline(t *testing.T) {
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return "", errors.New("S3 is not reachable")
+		},
+	}
+	fis := fiServiceImpl{
+		fit:     tm,
+		backoff: backoffConfig{initialInterval: 4 * time.Millisecond, maxInterval: 4 * time.Millisecond, deadline: 10 * time.Millisecond},
+	}
+
+	err := fis.Init()
+
+	assert.EqualError(t, err, "S3 is not reachable")
+	assert.False(t, fis.IsInitialised())
+	status := fis.Status()
+	assert.True(t, status.Attempts > 1, "Expected the initialisation to be retried")
+	assert.Equal(t, "S3 is not reachable", status.LastError)
+	assert.True(t, status.LastSuccess.IsZero())
+}
+
+func TestWithJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		wait := withJitter(time.Second)
+		if wait < 500*time.Millisecond || wait > time.Second {
+			t.Fatalf("Expected wait between 500ms and 1s. Actual: [%v]", wait)
+		}
+	}
+	assert.Equal(t, time.Duration(0), withJitter(0))
+}
+
+func TestFiServiceImpl_Init_PinnedFolderIsLoaded(t *testing.T) {
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			t.Error("Not expecting the weekly index to be read when a folder is pinned")
+			return "2017-08-17", nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			return map[string]financialInstrument{"foo": {}}, nil
+		},
+	}
+	fis := fiServiceImpl{fit: tm, pinnedFolder: "2017-08-03"}
+
+	err := fis.Init()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "2017-08-03", fis.Status().ResourcesFolder)
+	assert.Equal(t, "2017-08-03", fis.Status().PinnedFolder)
+}
+
+func TestFiServiceImpl_Pin(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		folder   string
+		err      error
+		expected string
+	}{
+		{
+			name:     "not a date",
+			folder:   "weekly",
+			err:      errInvalidResourcesFolder,
+			expected: "",
+		},
+		{
+			name:     "folder not in bucket",
+			folder:   "2017-07-27",
+			err:      errResourcesFolderNotFound,
+			expected: "",
+		},
+		{
+			name:     "existing folder",
+			folder:   "2017-08-03",
+			err:      nil,
+			expected: "2017-08-03",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tm := &transformerMock{
+				mockListResourcesFolders: func() ([]string, error) {
+					return []string{"2017-08-03", "2017-08-10"}, nil
+				},
+			}
+			fis := fiServiceImpl{fit: tm}
+
+			err := fis.Pin(tc.folder)
+
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.expected, fis.Status().PinnedFolder)
+		})
+	}
+}
+
+func TestFiServiceImpl_Refresh_PinnedFolderReplacesLatest(t *testing.T) {
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return "2017-08-10", nil
+		},
+		mockListResourcesFolders: func() ([]string, error) {
+			return []string{"2017-08-03", "2017-08-10"}, nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			return map[string]financialInstrument{"foo": {}}, nil
+		},
+	}
+	fis := serving(&fiServiceImpl{fit: tm}, "2017-08-10", map[string]financialInstrument{})
+
+	assert.NoError(t, fis.Pin("2017-08-03"))
+	assert.NoError(t, fis.Refresh())
+	assert.Equal(t, "2017-08-03", fis.Dataset().resourcesFolder)
+
+	fis.Unpin()
+	assert.NoError(t, fis.Refresh())
+	assert.Equal(t, "2017-08-10", fis.Dataset().resourcesFolder)
+}
+
+func TestFiServiceImpl_AlternativeIDLookups(t *testing.T) {
+	ipm := financialInstrument{
+		securityID:   "JBP7Z8-S",
+		securityName: "Industrija Precizne Mehanike AD",
+		figiCode:     "BBG000JPVHS1",
+		orgID:        "ea90a425-73be-33c5-9aa4-939c9a46b87a",
+	}
+	ipmPref := financialInstrument{
+		securityID:   "JBP7Z9-S",
+		securityName: "Industrija Precizne Mehanike AD PREF",
+		figiCode:     "BBG000JPVHT0",
+		orgID:        "ea90a425-73be-33c5-9aa4-939c9a46b87a",
+	}
+	m := map[string]financialInstrument{
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": ipm,
+		"2d9e7c4d-0d7b-3bd4-8a6b-0fd7a1f6b8c5": ipmPref,
+	}
+	fis := serving(&fiServiceImpl{}, "2017-08-10", m)
+
+	id, fi, present := fis.Dataset().ReadByFIGI("BBG000JPVHS1")
+	assert.True(t, present)
+	assert.Equal(t, "404c8329-3f8e-348e-ba32-cf3eb2c1ffed", id)
+	assert.Equal(t, ipm, fi)
+
+	id, fi, present = fis.Dataset().ReadByFactsetID("JBP7Z9-S")
+	assert.True(t, present)
+	assert.Equal(t, "2d9e7c4d-0d7b-3bd4-8a6b-0fd7a1f6b8c5", id)
+	assert.Equal(t, ipmPref, fi)
+
+	_, _, present = fis.Dataset().ReadByFIGI("BBG000BDN0W4")
+	assert.False(t, present)
+
+	_, _, present = fis.Dataset().ReadByFactsetID("GG9B0P-S")
+	assert.False(t, present)
+
+	assert.Equal(t, []string{"2d9e7c4d-0d7b-3bd4-8a6b-0fd7a1f6b8c5", "404c8329-3f8e-348e-ba32-cf3eb2c1ffed"}, fis.Dataset().IssuedBy("ea90a425-73be-33c5-9aa4-939c9a46b87a"))
+	assert.Empty(t, fis.Dataset().IssuedBy("21fbf032-23e7-34d3-970c-45432b455fd9"))
+}
+
+func TestFiServiceImpl_ReadByFIGI_SecondaryListing(t *testing.T) {
+	mks := financialInstrument{
+		securityID:   "GG9B0P-S",
+		securityName: "Marks & Spencer Group Plc",
+		figiCode:     "BBG000BDN0W4",
+		orgID:        "21fbf032-23e7-34d3-970c-45432b455fd9",
+		listings: []listing{
+			{listingID: "MLKNP9-L", figiCode: "BBG000BDN0W4", primary: true},
+			{listingID: "T9X6PL-L", figiCode: "BBG000BDN1K7"},
+		},
+	}
+	other := financialInstrument{
+		securityID:   "JBP7Z8-S",
+		securityName: "Industrija Precizne Mehanike AD",
+		figiCode:     "BBG000BDN1K7",
+		orgID:        "ea90a425-73be-33c5-9aa4-939c9a46b87a",
+	}
+	m := map[string]financialInstrument{
+		"8404dbec-2423-322d-9afa-f92e553e53b6": mks,
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": other,
+	}
+	fis := serving(&fiServiceImpl{}, "2017-08-10", m)
+
+	id, fi, present := fis.Dataset().ReadByFIGI("BBG000BDN0W4")
+	assert.True(t, present)
+	assert.Equal(t, "8404dbec-2423-322d-9afa-f92e553e53b6", id)
+	assert.Equal(t, mks, fi)
+
+	// the FIGI of a primary listing wins over the same FIGI on a secondary listing
+	id, _, present = fis.Dataset().ReadByFIGI("BBG000BDN1K7")
+	assert.True(t, present)
+	assert.Equal(t, "404c8329-3f8e-348e-ba32-cf3eb2c1ffed", id)
+
+	delete(m, "404c8329-3f8e-348e-ba32-cf3eb2c1ffed")
+	fis = serving(&fiServiceImpl{}, "2017-08-10", m)
+	id, _, present = fis.Dataset().ReadByFIGI("BBG000BDN1K7")
+	assert.True(t, present)
+	assert.Equal(t, "8404dbec-2423-322d-9afa-f92e553e53b6", id)
+}
+
+func TestFiServiceImpl_AlternativeIDLookups_NotInitialisedService(t *testing.T) {
+	fis := fiServiceImpl{}
+
+	_, _, present := fis.Dataset().ReadByFIGI("BBG000JPVHS1")
+	assert.False(t, present)
+	_, _, present = fis.Dataset().ReadByFactsetID("JBP7Z8-S")
+	assert.False(t, present)
+	assert.Empty(t, fis.Dataset().IssuedBy("ea90a425-73be-33c5-9aa4-939c9a46b87a"))
+}
+
+func TestFiServiceImpl_SnapshotIsRestoredAfterRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fis_test_snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := newSnapshotStore(filepath.Join(dir, "snapshot.json"))
+
+	expected := map[string]financialInstrument{"7d4fdd8b-3bad-3766-af4a-b26a7bc56f10": {securityID: "S10JZW-S-CA"}}
+	tm := &transformerMock{
+		mockFindLatestResourcesFolder: func() (string, error) {
+			return "2017-08-10", nil
+		},
+		mockTransform: func() (map[string]financialInstrument, error) {
+			return expected, nil
+		},
 	}
+	fis := fiServiceImpl{fit: tm, snapshots: store}
+	require.NoError(t, fis.Init())
+
+	restarted := fiServiceImpl{fit: tm, snapshots: store}
+	assert.True(t, restarted.restoreSnapshot())
+
+	assert.True(t, restarted.IsInitialised())
+	assert.Equal(t, expected, restarted.Dataset().financialInstruments)
+	assert.Equal(t, "2017-08-10", restarted.Status().ResourcesFolder)
+	assert.Equal(t, []string{"7d4fdd8b-3bad-3766-af4a-b26a7bc56f10"}, restarted.Dataset().IDs())
+}
+
+func TestFiServiceImpl_RestoreSnapshot_NoSnapshot(t *testing.T) {
+	fis := fiServiceImpl{snapshots: newSnapshotStore(filepath.Join(os.TempDir(), "fis_test_snapshot_that_does_not_exist.json"))}
+
+	assert.False(t, fis.restoreSnapshot())
+	assert.False(t, fis.IsInitialised())
+
+	disabled := fiServiceImpl{}
+	assert.False(t, disabled.restoreSnapshot())
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// snapshotVersion must be increased whenever the snapshot layout changes, so that snapshots
+// written by a previous version of the service are ignored rather than misread.
+const snapshotVersion = 5
+
+type snapshot struct {
+	Version              int                   `json:"version"`
+	ResourcesFolder      string                `json:"resourcesFolder"`
+	Created              time.Time             `json:"created"`
+	FinancialInstruments map[string]snapshotFI `json:"financialInstruments"`
+	FIGIConflicts        []figiConflict        `json:"figiConflicts"`
+	TransformReport      transformReport       `json:"transformReport"`
+	// Unpublished lists the financial instruments whose changes were not published when the snapshot was saved.
+	// It is missing from the snapshots written before they were kept, which then have nothing left to publish.
+	Unpublished []string `json:"unpublished,omitempty"`
+}
+
+type snapshotFI struct {
+	FIGI          string       `json:"figiCode"`
+	SecurityID    string       `json:"factsetIdentifier"`
+	OrgID         string       `json:"issuedBy"`
+	SecurityName  string       `json:"prefLabel"`
+	Ticker        string       `json:"ticker,omitempty"`
+	Exchange      string       `json:"exchange,omitempty"`
+	Currency      string       `json:"currency,omitempty"`
+	IssuerCountry string       `json:"issuerCountry,omitempty"`
+	Listings      []uppListing `json:"listings,omitempty"`
+}
+
+// snapshotStore keeps the last successfully transformed dataset in a local file,
+// so that a restarted service can serve it while the latest dataset is being transformed.
+type snapshotStore struct {
+	path string
+}
+
+func newSnapshotStore(path string) *snapshotStore {
+	return &snapshotStore{path: path}
+}
+
+// save writes the snapshot to a temporary file first and renames it, so a crash never leaves a truncated snapshot behind.
+func (s *snapshotStore) save(folder string, created time.Time, result transformResult, unpublished []string) error {
+	snap := snapshot{
+		Version:              snapshotVersion,
+		ResourcesFolder:      folder,
+		Created:              created,
+		FinancialInstruments: make(map[string]snapshotFI, len(result.financialInstruments)),
+		FIGIConflicts:        result.figiConflicts,
+		TransformReport:      result.report,
+		Unpublished:          unpublished,
+	}
+	for UUID, fi := range result.financialInstruments {
+		snap.FinancialInstruments[UUID] = snapshotFI{
+			FIGI:          fi.figiCode,
+			SecurityID:    fi.securityID,
+			OrgID:         fi.orgID,
+			SecurityName:  fi.securityName,
+			Ticker:        fi.ticker,
+			Exchange:      fi.exchange,
+			Currency:      fi.currency,
+			IssuerCountry: fi.issuerCountry,
+			Listings:      toUppListings(fi.listings),
+		}
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := json.NewEncoder(tmp).Encode(snap); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// load reads the snapshot. A missing snapshot file is reported with an error satisfying os.IsNotExist.
+func (s *snapshotStore) load() (snapshot, transformResult, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return snapshot{}, transformResult{}, err
+	}
+	defer f.Close()
+
+	var snap snapshot
+	if err := json.NewDecoder(f).Decode(&snap); err != nil {
+		return snapshot{}, transformResult{}, errors.Wrapf(err, "could not decode snapshot [%s]", s.path)
+	}
+	if snap.Version != snapshotVersion {
+		return snapshot{}, transformResult{}, errors.Errorf("snapshot [%s] has version [%d], expected [%d]", s.path, snap.Version, snapshotVersion)
+	}
+
+	fis := make(map[string]financialInstrument, len(snap.FinancialInstruments))
+	for UUID, fi := range snap.FinancialInstruments {
+		fis[UUID] = financialInstrument{
+			figiCode:      fi.FIGI,
+			securityID:    fi.SecurityID,
+			orgID:         fi.OrgID,
+			securityName:  fi.SecurityName,
+			ticker:        fi.Ticker,
+			exchange:      fi.Exchange,
+			currency:      fi.Currency,
+			issuerCountry: fi.IssuerCountry,
+			listings:      fromUppListings(fi.Listings),
+		}
+	}
+	result := transformResult{financialInstruments: fis, figiConflicts: snap.FIGIConflicts, report: snap.TransformReport}
+	snap.FinancialInstruments = nil
+	snap.FIGIConflicts = nil
+	snap.TransformReport = transformReport{}
+	return snap, result, nil
+}
+
+func fromUppListings(uppListings []uppListing) []listing {
+	var listings []listing
+	for _, l := range uppListings {
+		listings = append(listings, listing{
+			listingID: l.FactsetID,
+			figiCode:  l.FIGI,
+			ticker:    l.Ticker,
+			exchange:  l.Exchange,
+			currency:  l.Currency,
+			primary:   l.Primary,
+		})
+	}
+	return listings
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotStore_SaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fis_test_snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := newSnapshotStore(filepath.Join(dir, "snapshot.json"))
+	created := time.Date(2017, time.August, 10, 9, 30, 0, 0, time.UTC)
+	expected := map[string]financialInstrument{
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": {
+			figiCode:      "BBG000JPVHS1",
+			securityID:    "JBP7Z8-S",
+			orgID:         "ea90a425-73be-33c5-9aa4-939c9a46b87a",
+			securityName:  "Industrija Precizne Mehanike AD",
+			ticker:        "IPMB SG",
+			exchange:      "BEL",
+			currency:      "RSD",
+			issuerCountry: "RS",
+			listings: []listing{
+				{listingID: "M679DF-L", figiCode: "BBG000JPVHS1", ticker: "IPMB SG", exchange: "BEL", currency: "RSD", primary: true},
+				{listingID: "T9X6PL-L", exchange: "OTC", currency: "USD"},
+			},
+		},
+	}
+
+	conflicts := []figiConflict{
+		{
+			Reason:    figiSharedBySecurities,
+			Kept:      conflictRecord{FactsetID: "JBP7Z8-S", FIGI: "BBG000JPVHS1"},
+			Discarded: []conflictRecord{{FactsetID: "JBP7Z9-S", FIGI: "BBG000JPVHS1"}},
+		},
+	}
+
+	report := transformReport{
+		ResourcesFolder:      "2017-08-10",
+		Started:              created.Add(-time.Minute),
+		Finished:             created,
+		Stages:               []*stageStats{{Stage: "figiCodes", File: secToFIGIs, RowsRead: 3, RowsSkipped: 1, SkipReasons: map[string]int{skipUnknownListing: 1}}},
+		FinancialInstruments: 1,
+	}
+
+	unpublished := []string{"404c8329-3f8e-348e-ba32-cf3eb2c1ffed", "8404dbec-2423-322d-9afa-f92e553e53b6"}
+	require.NoError(t, store.save("2017-08-10", created, transformResult{financialInstruments: expected, figiConflicts: conflicts, report: report}, unpublished))
+
+	snap, result, err := store.load()
+	require.NoError(t, err)
+	assert.Equal(t, "2017-08-10", snap.ResourcesFolder)
+	assert.True(t, created.Equal(snap.Created))
+	assert.Equal(t, expected, result.financialInstruments)
+	assert.Equal(t, conflicts, result.figiConflicts)
+	assert.True(t, report.Started.Equal(result.report.Started))
+	assert.Equal(t, report.Stages, result.report.Stages)
+	assert.Equal(t, report.FinancialInstruments, result.report.FinancialInstruments)
+	assert.Equal(t, unpublished, snap.Unpublished)
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "Temporary snapshot files should be cleaned up")
+}
+
+func TestSnapshotStore_Load_MissingFile(t *testing.T) {
+	store := newSnapshotStore(filepath.Join(os.TempDir(), "fis_test_snapshot_that_does_not_exist.json"))
+
+	_, _, err := store.load()
+
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSnapshotStore_Load_UnknownVersion(t *testing.T) {
+	f, err := ioutil.TempFile("", "fis_test_snapshot")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`{"version":0,"resourcesFolder":"2017-08-10","financialInstruments":{}}`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, _, err = newSnapshotStore(f.Name()).load()
+
+	assert.Error(t, err)
+	assert.False(t, os.IsNotExist(err))
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/md5"
 	"github.com/pborman/uuid"
 	"io"
+	"sort"
 	"time"
 )
 
@@ -15,7 +16,10 @@ const (
 )
 
 type fiTransformer interface {
-	Transform() (map[string]financialInstrument, error)
+	Transform(folder string, dailies []string) (transformResult, error)
+	findLatestResourcesFolder() (string, error)
+	findDailyFolders(folder string) ([]string, error)
+	listResourcesFolders() ([]string, error)
 	checkConnectivityToS3() error
 }
 
@@ -27,34 +31,48 @@ type fiTransformerImpl struct {
 type fiMappings struct {
 	figiCodeToSecurityIDs               map[string]string
 	securityIDtoRawFinancialInstruments map[string]rawFinancialInstrument
+	listingIDToCodes                    map[string]listingCodes
+	figiConflicts                       []figiConflict
 }
 
-func (fit *fiTransformerImpl) Transform() (map[string]financialInstrument, error) {
-	infoLogger.Println("Started loading FIs.")
-	start := time.Now()
+type transformResult struct {
+	financialInstruments map[string]financialInstrument
+	figiConflicts        []figiConflict
+	report               transformReport
+}
+
+// Transform transforms the weekly zip of the given folder, with the daily zips of the given folders applied on top of it in order.
+func (fit *fiTransformerImpl) Transform(folder string, dailies []string) (transformResult, error) {
+	infoLogger.Printf("Started loading FIs from folder [%s] with daily folders %v.", folder, dailies)
+	report := transformReport{ResourcesFolder: folder, DailyFolders: dailies, Started: time.Now()}
 
-	mappings, err := getMappings(*fit)
+	mappings, err := getMappings(*fit, folder, dailies, &report)
 	if err != nil {
-		return map[string]financialInstrument{}, err
+		return transformResult{}, err
 	}
 
-	fis := transformMappings(mappings)
-	infoLogger.Printf("Loading FIs finished in [%v]", time.Since(start))
-	infoLogger.Printf("Nr of FIs: [%v]", len(fis))
-
-	return fis, nil
-}
+	fis, conflicts := transformMappings(mappings)
+	report.WithoutFIGI = countWithoutFIGI(mappings)
+	report.FinancialInstruments = len(fis)
+	report.Finished = time.Now()
 
-func getMappings(fit fiTransformerImpl) (fiMappings, error) {
-	latestResourcesFolderName, err := fit.loader.FindLatestResourcesFolder()
-	if err != nil {
-		return fiMappings{}, err
+	conflicts = append(mappings.figiConflicts, conflicts...)
+	sortFIGIConflicts(conflicts)
+	for _, c := range conflicts {
+		infoLogger.Printf("FIGI conflict [%s]: kept [%v], discarded [%v]", c.Reason, c.Kept, c.Discarded)
 	}
+	infoLogger.Printf("Loading FIs finished in [%v]", report.Finished.Sub(report.Started))
+	infoLogger.Printf("Nr of FIs: [%v]. Nr of FIGI conflicts: [%v]", len(fis), len(conflicts))
+
+	return transformResult{financialInstruments: fis, figiConflicts: conflicts, report: report}, nil
+}
 
-	r, err := fit.loader.GetResourceBundle(latestResourcesFolderName)
+func getMappings(fit fiTransformerImpl, folder string, dailies []string, report *transformReport) (fiMappings, error) {
+	r, err := fit.openBundle(folder, dailies)
 	if err != nil {
 		return fiMappings{}, err
 	}
+	defer r.Close()
 
 	secReader, err := r.get(securities)
 	if err != nil {
@@ -68,7 +86,7 @@ func getMappings(fit fiTransformerImpl) (fiMappings, error) {
 	}
 	defer secToOrgReader.Close()
 
-	fis, err := fit.parser.parseFIs(secReader, secToOrgReader)
+	fis, err := fit.parser.parseFIs(secReader, secToOrgReader, report)
 	if err != nil {
 		return fiMappings{}, err
 	}
@@ -78,15 +96,22 @@ func getMappings(fit fiTransformerImpl) (fiMappings, error) {
 		if err != nil {
 			return fiMappings{}, err
 		}
-		pubEnts := parseEntities(entReader)
-		applyPublicEntityFilter(fis, pubEnts)
+		defer entReader.Close()
+		pubEnts, err := parseEntities(entReader, report)
+		if err != nil {
+			return fiMappings{}, err
+		}
+		report.FilteredAsNonPublic = applyPublicEntityFilter(fis, pubEnts)
 	}
 	lisReader, err := r.get(securities)
 	if err != nil {
 		return fiMappings{}, err
 	}
 	defer lisReader.Close()
-	listings := fit.parser.parseListings(lisReader, fis)
+	listings, err := fit.parser.parseListings(lisReader, fis, report)
+	if err != nil {
+		return fiMappings{}, err
+	}
 
 	figiReader, err := r.get(secToFIGIs)
 	if err != nil {
@@ -94,7 +119,17 @@ func getMappings(fit fiTransformerImpl) (fiMappings, error) {
 	}
 	defer figiReader.Close()
 
-	figis, err := fit.parser.parseFIGICodes(figiReader, listings)
+	figis, conflicts, err := fit.parser.parseFIGICodes(figiReader, listings, report)
+	if err != nil {
+		return fiMappings{}, err
+	}
+
+	codesReader, err := r.get(secToFIGIs)
+	if err != nil {
+		return fiMappings{}, err
+	}
+	defer codesReader.Close()
+	codes, err := fit.parser.parseListingCodes(codesReader, fis, report)
 	if err != nil {
 		return fiMappings{}, err
 	}
@@ -102,31 +137,140 @@ func getMappings(fit fiTransformerImpl) (fiMappings, error) {
 	return fiMappings{
 		securityIDtoRawFinancialInstruments: fis,
 		figiCodeToSecurityIDs:               figis,
+		listingIDToCodes:                    codes,
+		figiConflicts:                       conflicts,
 	}, nil
 }
 
-func applyPublicEntityFilter(fis map[string]rawFinancialInstrument, pubEnts map[string]bool) {
+// openBundle opens the weekly zip of the folder with the daily zips of the given folders applied on top of it.
+func (fit *fiTransformerImpl) openBundle(folder string, dailies []string) (resourceBundle, error) {
+	weekly, err := fit.loader.GetResourceBundle(folder)
+	if err != nil {
+		return nil, err
+	}
+	if len(dailies) == 0 {
+		return weekly, nil
+	}
+	dailyBundles := make([]resourceBundle, 0, len(dailies))
+	for _, daily := range dailies {
+		b, err := fit.loader.GetDailyBundle(daily)
+		if err != nil {
+			newDeltaBundle(weekly, dailyBundles).Close()
+			return nil, err
+		}
+		dailyBundles = append(dailyBundles, b)
+	}
+	return newDeltaBundle(weekly, dailyBundles), nil
+}
+
+// applyPublicEntityFilter removes the financial instruments not issued by a public entity, sets the issuer country
+// of the others and returns how many were removed.
+func applyPublicEntityFilter(fis map[string]rawFinancialInstrument, pubEnts map[string]string) int {
+	filtered := 0
 	for k, fi := range fis {
-		if _, present := pubEnts[fi.orgID]; !present {
+		country, present := pubEnts[fi.orgID]
+		if !present {
 			delete(fis, k)
+			filtered++
+			continue
 		}
+		fi.issuerCountry = country
+		fis[k] = fi
 	}
 	infoLogger.Println("Number of fis after filtering non-public companies:", len(fis))
+	return filtered
 }
 
-func transformMappings(fiData fiMappings) map[string]financialInstrument {
-	fis := make(map[string]financialInstrument)
+// countWithoutFIGI returns the number of securities none of whose FIGI codes were found, which are therefore not transformed.
+func countWithoutFIGI(fiData fiMappings) int {
+	withFIGI := make(map[string]bool)
+	for _, sID := range fiData.figiCodeToSecurityIDs {
+		withFIGI[sID] = true
+	}
+	count := 0
+	for sID := range fiData.securityIDtoRawFinancialInstruments {
+		if !withFIGI[sID] {
+			count++
+		}
+	}
+	return count
+}
+
+// transformMappings builds one financial instrument per security. A security with several FIGIs keeps the lowest one,
+// and the conflict is reported.
+func transformMappings(fiData fiMappings) (map[string]financialInstrument, []figiConflict) {
+	figisBySecurityID := make(map[string][]string)
 	for figi, sID := range fiData.figiCodeToSecurityIDs {
+		figisBySecurityID[sID] = append(figisBySecurityID[sID], figi)
+	}
+
+	fis := make(map[string]financialInstrument)
+	var conflicts []figiConflict
+	for sID, figis := range figisBySecurityID {
+		sort.Strings(figis)
+		if len(figis) > 1 {
+			conflict := figiConflict{
+				Reason: securityWithSeveralFIGIs,
+				Kept:   conflictRecord{FactsetID: sID, FIGI: figis[0]},
+			}
+			for _, discarded := range figis[1:] {
+				conflict.Discarded = append(conflict.Discarded, conflictRecord{FactsetID: sID, FIGI: discarded})
+			}
+			conflicts = append(conflicts, conflict)
+		}
+
 		r := fiData.securityIDtoRawFinancialInstruments[sID]
+		listings, ticker := codedListings(r.listings, fiData.listingIDToCodes)
 		uid := uuid.NewMD5(uuid.UUID{}, []byte(r.securityID)).String()
 		fis[uid] = financialInstrument{
-			figiCode:     figi,
-			orgID:        doubleMD5Hash(r.orgID),
-			securityID:   r.securityID,
-			securityName: r.securityName,
+			figiCode:      figis[0],
+			orgID:         doubleMD5Hash(r.orgID),
+			securityID:    r.securityID,
+			securityName:  r.securityName,
+			ticker:        ticker,
+			exchange:      r.exchange,
+			currency:      r.currency,
+			issuerCountry: r.issuerCountry,
+			listings:      listings,
+		}
+	}
+	sortFIGIConflicts(conflicts)
+	return fis, conflicts
+}
+
+// codedListings sets the Bloomberg codes of the listings, and sorts them with the primary listing first.
+// It also returns the ticker of the primary listing.
+func codedListings(raw []listing, codes map[string]listingCodes) ([]listing, string) {
+	var listings []listing
+	ticker := ""
+	for _, l := range raw {
+		c := codes[l.listingID]
+		l.figiCode = c.figiCode
+		l.ticker = c.ticker
+		if l.primary {
+			ticker = l.ticker
 		}
+		listings = append(listings, l)
 	}
-	return fis
+	sort.Slice(listings, func(i, j int) bool {
+		if listings[i].primary != listings[j].primary {
+			return listings[i].primary
+		}
+		return listings[i].listingID < listings[j].listingID
+	})
+	return listings, ticker
+}
+
+func sortFIGIConflicts(conflicts []figiConflict) {
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Reason != conflicts[j].Reason {
+			return conflicts[i].Reason < conflicts[j].Reason
+		}
+		if conflicts[i].Kept.FIGI != conflicts[j].Kept.FIGI {
+			return conflicts[i].Kept.FIGI < conflicts[j].Kept.FIGI
+		}
+		return conflicts[i].Kept.FactsetID < conflicts[j].Kept.FactsetID
+	})
 }
 
 //same as in org-transformer
@@ -136,6 +280,38 @@ func doubleMD5Hash(input string) string {
 	return uuid.NewMD5(uuid.UUID{}, h.Sum(nil)).String()
 }
 
+func (fit *fiTransformerImpl) findLatestResourcesFolder() (string, error) {
+	return fit.loader.FindLatestResourcesFolder()
+}
+
+// findDailyFolders returns the daily folders to apply on top of the weekly zip of the given folder:
+// the ones after it and before the next weekly folder, whose weekly zip already includes them.
+func (fit *fiTransformerImpl) findDailyFolders(folder string) ([]string, error) {
+	weeklies, dailies, err := fit.loader.ListFolders()
+	if err != nil {
+		return nil, err
+	}
+	next := ""
+	for _, weekly := range weeklies {
+		if weekly > folder {
+			next = weekly
+			break
+		}
+	}
+
+	var applicable []string
+	for _, daily := range dailies {
+		if daily > folder && (next == "" || daily < next) {
+			applicable = append(applicable, daily)
+		}
+	}
+	return applicable, nil
+}
+
+func (fit *fiTransformerImpl) listResourcesFolders() ([]string, error) {
+	return fit.loader.ListResourcesFolders()
+}
+
 func (fit *fiTransformerImpl) checkConnectivityToS3() error {
 	_, err := fit.loader.BucketExists()
 	if err != nil {
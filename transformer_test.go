@@ -1,10 +1,12 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
@@ -12,22 +14,35 @@ import (
 
 type loaderMock struct {
 	mockFindLatestResourcesFolder func() (string, error)
+	mockListResourcesFolders      func() ([]string, error)
 	mockBucketExists              func() (bool, error)
 	mockGetResourceBundle         func(pathPrefix string) (resourceBundle, error)
+	mockListFolders               func() ([]string, []string, error)
+	mockGetDailyBundle            func(pathPrefix string) (resourceBundle, error)
 }
 
 type mockResourceBundle struct {
 	mockGet func(name string) (io.ReadCloser, error)
+	closed  bool
 }
 
 func (rb *mockResourceBundle) get(name string) (io.ReadCloser, error) {
 	return rb.mockGet(name)
 }
 
+func (rb *mockResourceBundle) Close() error {
+	rb.closed = true
+	return nil
+}
+
 func (l *loaderMock) FindLatestResourcesFolder() (string, error) {
 	return l.mockFindLatestResourcesFolder()
 }
 
+func (l *loaderMock) ListResourcesFolders() ([]string, error) {
+	return l.mockListResourcesFolders()
+}
+
 func (l *loaderMock) BucketExists() (bool, error) {
 	return l.mockBucketExists()
 }
@@ -36,26 +51,44 @@ func (l *loaderMock) GetResourceBundle(pathPrefix string) (resourceBundle, error
 	return l.mockGetResourceBundle(pathPrefix)
 }
 
+func (l *loaderMock) ListFolders() ([]string, []string, error) {
+	return l.mockListFolders()
+}
+
+func (l *loaderMock) GetDailyBundle(pathPrefix string) (resourceBundle, error) {
+	return l.mockGetDailyBundle(pathPrefix)
+}
+
 type parserMock struct {
-	mockParseFIs       func() (map[string]rawFinancialInstrument, error)
-	mockParseFIGICodes func() (map[string]string, error)
-	mockParseListings  func() map[string]string
-	mockParseEntities  func(r io.ReadCloser) map[string]bool
+	mockParseFIs          func() (map[string]rawFinancialInstrument, error)
+	mockParseFIGICodes    func() (map[string]string, error)
+	mockFIGIConflicts     []figiConflict
+	mockParseListings     func() map[string]string
+	mockParseListingCodes func() map[string]listingCodes
+	mockParseEntities     func(r io.ReadCloser, report *transformReport) (map[string]string, error)
 }
 
-func (p *parserMock) parseFIs(r1, r2 io.Reader) (map[string]rawFinancialInstrument, error) {
+func (p *parserMock) parseFIs(r1, r2 io.Reader, report *transformReport) (map[string]rawFinancialInstrument, error) {
 	return p.mockParseFIs()
 }
 
-func (p *parserMock) parseFIGICodes(r io.Reader, m map[string]string) (map[string]string, error) {
-	return p.mockParseFIGICodes()
+func (p *parserMock) parseFIGICodes(r io.Reader, m map[string]string, report *transformReport) (map[string]string, []figiConflict, error) {
+	figis, err := p.mockParseFIGICodes()
+	return figis, p.mockFIGIConflicts, err
+}
+
+func (p *parserMock) parseListings(r io.Reader, m map[string]rawFinancialInstrument, report *transformReport) (map[string]string, error) {
+	return p.mockParseListings(), nil
 }
 
-func (p *parserMock) parseListings(r io.Reader, m map[string]rawFinancialInstrument) map[string]string {
-	return p.mockParseListings()
+func (p *parserMock) parseListingCodes(r io.Reader, m map[string]rawFinancialInstrument, report *transformReport) (map[string]listingCodes, error) {
+	if p.mockParseListingCodes == nil {
+		return nil, nil
+	}
+	return p.mockParseListingCodes(), nil
 }
 
-func (p *parserMock) parseEntityFunc() func(r io.ReadCloser) map[string]bool {
+func (p *parserMock) parseEntityFunc() func(r io.ReadCloser, report *transformReport) (map[string]string, error) {
 	return p.mockParseEntities
 }
 
@@ -183,12 +216,20 @@ func TestGetMappings(t *testing.T) {
 						"BBG000123NMAV": "ABCDEF-S",
 					}, nil
 				},
+				mockParseListingCodes: func() map[string]listingCodes {
+					return map[string]listingCodes{
+						"LKJHHM-L": {figiCode: "BBG000123NMAV", ticker: "FOO US"},
+					}
+				},
 			},
 			err: nil,
 			expected: fiMappings{
 				figiCodeToSecurityIDs: map[string]string{
 					"BBG000123NMAV": "ABCDEF-S",
 				},
+				listingIDToCodes: map[string]listingCodes{
+					"LKJHHM-L": {figiCode: "BBG000123NMAV", ticker: "FOO US"},
+				},
 				securityIDtoRawFinancialInstruments: map[string]rawFinancialInstrument{
 					"ABCDEF-S": {
 						securityID:       "ABCDEF-S",
@@ -204,7 +245,7 @@ func TestGetMappings(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(fmt.Sprintf("Case [%v]", tc.nm), func(t *testing.T) {
-			m, err := getMappings(fiTransformerImpl{tc.lm, tc.pm})
+			m, err := getMappings(fiTransformerImpl{tc.lm, tc.pm}, "", nil, &transformReport{})
 			if err != tc.err {
 				t.Errorf("Expected error: [%v]. Actual: [%v]", tc.err, err)
 			}
@@ -215,15 +256,145 @@ func TestGetMappings(t *testing.T) {
 	}
 }
 
+func TestGetMappings_BundlesAreClosed(t *testing.T) {
+	var opened []*mockResourceBundle
+	open := func(pathPrefix string) (resourceBundle, error) {
+		if pathPrefix == "2017-08-15" {
+			return nil, errLoader
+		}
+		b := &mockResourceBundle{
+			mockGet: func(name string) (io.ReadCloser, error) {
+				return ioutil.NopCloser(strings.NewReader("")), nil
+			},
+		}
+		opened = append(opened, b)
+		return b, nil
+	}
+	fit := fiTransformerImpl{
+		loader: &loaderMock{mockGetResourceBundle: open, mockGetDailyBundle: open},
+		parser: &parserMock{
+			mockParseFIs: func() (map[string]rawFinancialInstrument, error) {
+				return map[string]rawFinancialInstrument{}, nil
+			},
+			mockParseListings: func() map[string]string {
+				return map[string]string{}
+			},
+			mockParseFIGICodes: func() (map[string]string, error) {
+				return map[string]string{}, nil
+			},
+		},
+	}
+
+	var testCases = []struct {
+		name    string
+		dailies []string
+		err     error
+	}{
+		{name: "transformed", dailies: []string{"2017-08-14"}},
+		{name: "daily zip missing", dailies: []string{"2017-08-14", "2017-08-15"}, err: errLoader},
+	}
+
+	for _, tc := range testCases {
+		opened = nil
+		if _, err := getMappings(fit, "2017-08-10", tc.dailies, &transformReport{}); err != tc.err {
+			t.Errorf("Case [%s]. Expected error: [%v]. Actual: [%v]", tc.name, tc.err, err)
+		}
+		if len(opened) != 2 {
+			t.Fatalf("Case [%s]. Expected 2 bundles opened. Actual: [%d]", tc.name, len(opened))
+		}
+		for i, b := range opened {
+			if !b.closed {
+				t.Errorf("Case [%s]. Bundle [%d] was not closed", tc.name, i)
+			}
+		}
+	}
+}
+
+func TestTransform_LocalDataset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fis_test_data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeLocalDataset(t, dir, "2017-08-10")
+
+	l := newFSLoader(dir)
+	fit := &fiTransformerImpl{loader: &l, parser: newFIParser(defaultSelectionRules)}
+
+	result, err := fit.Transform("2017-08-10", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fis := result.financialInstruments
+
+	expected := map[string]financialInstrument{
+		"8404dbec-2423-322d-9afa-f92e553e53b6": {
+			figiCode:      "BBG000BDN0W4",
+			securityID:    "GG9B0P-S",
+			orgID:         "21fbf032-23e7-34d3-970c-45432b455fd9",
+			securityName:  "Marks & Spencer Group Plc",
+			ticker:        "MKS LN",
+			exchange:      "LON",
+			currency:      "GBP",
+			issuerCountry: "GB",
+			listings: []listing{
+				{listingID: "MLKNP9-L", figiCode: "BBG000BDN0W4", ticker: "MKS LN", exchange: "LON", currency: "GBP", primary: true},
+			},
+		},
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": {
+			figiCode:      "BBG000JPVHS1",
+			securityID:    "JBP7Z8-S",
+			orgID:         "ea90a425-73be-33c5-9aa4-939c9a46b87a",
+			securityName:  "Industrija Precizne Mehanike AD",
+			ticker:        "IPMB SG",
+			exchange:      "BEL",
+			currency:      "RSD",
+			issuerCountry: "RS",
+			listings: []listing{
+				{listingID: "M679DF-L", figiCode: "BBG000JPVHS1", ticker: "IPMB SG", exchange: "BEL", currency: "RSD", primary: true},
+			},
+		},
+	}
+	if !reflect.DeepEqual(fis, expected) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expected, fis)
+	}
+	report := result.report
+	if report.ResourcesFolder != "2017-08-10" || report.Started.IsZero() || report.Finished.Before(report.Started) {
+		t.Errorf("Unexpected report header: [%+v]", report)
+	}
+	expectedStages := []*stageStats{
+		{Stage: "securities", File: securities, RowsRead: 6, RowsSkipped: 3, SkipReasons: map[string]int{skipSecurityIDSuffixRejected: 3}, Rules: []*ruleStats{
+			{Rule: ruleUniverseType, Accepted: 6},
+			{Rule: ruleSecurityIDSuffix, Accepted: 3, Rejected: 3},
+			{Rule: ruleActive, Accepted: 3},
+			{Rule: rulePrimaryEquity, Accepted: 3},
+			{Rule: ruleSecurityType, Accepted: 3},
+		}},
+		{Stage: "securityEntityMap", File: securityEntityMap, RowsRead: 3, RowsSkipped: 0, SkipReasons: map[string]int{}},
+		{Stage: "entities", File: entities, RowsRead: 3, RowsSkipped: 1, SkipReasons: map[string]int{skipEntityTypeRejected: 1}, Rules: []*ruleStats{
+			{Rule: ruleEntityType, Accepted: 2, Rejected: 1},
+		}},
+		{Stage: "listings", File: securities, RowsRead: 6, RowsSkipped: 4, SkipReasons: map[string]int{skipNotRegional: 3, skipUnknownSecurity: 1}},
+		{Stage: "figiCodes", File: secToFIGIs, RowsRead: 3, RowsSkipped: 1, SkipReasons: map[string]int{skipUnknownListing: 1}},
+		{Stage: "listingCodes", File: secToFIGIs, RowsRead: 3, RowsSkipped: 1, SkipReasons: map[string]int{skipUnknownListing: 1}},
+	}
+	if !reflect.DeepEqual(report.Stages, expectedStages) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expectedStages, report.Stages)
+	}
+	if report.FilteredAsNonPublic != 1 || report.WithoutFIGI != 0 || report.FinancialInstruments != 2 {
+		t.Errorf("Unexpected report totals: [%+v]", report)
+	}
+}
+
 func TestApplyPublicEntityFiltering(t *testing.T) {
 	var tests = []struct {
 		rawFIs   map[string]rawFinancialInstrument
-		pubEnts  map[string]bool
+		pubEnts  map[string]string
 		expected map[string]rawFinancialInstrument
 	}{
 		{
 			rawFIs:   map[string]rawFinancialInstrument{},
-			pubEnts:  map[string]bool{},
+			pubEnts:  map[string]string{},
 			expected: map[string]rawFinancialInstrument{},
 		},
 		// empty orgID
@@ -237,8 +408,8 @@ func TestApplyPublicEntityFiltering(t *testing.T) {
 					primaryListingID: "LKJHHM-L",
 				},
 			},
-			pubEnts: map[string]bool{
-				"MNBVCX-E": true,
+			pubEnts: map[string]string{
+				"MNBVCX-E": "US",
 			},
 			expected: map[string]rawFinancialInstrument{},
 		},
@@ -253,8 +424,8 @@ func TestApplyPublicEntityFiltering(t *testing.T) {
 					primaryListingID: "LKJHHM-L",
 				},
 			},
-			pubEnts: map[string]bool{
-				"MNBVCX-EE": true,
+			pubEnts: map[string]string{
+				"MNBVCX-EE": "US",
 			},
 			expected: map[string]rawFinancialInstrument{},
 		},
@@ -269,8 +440,8 @@ func TestApplyPublicEntityFiltering(t *testing.T) {
 					primaryListingID: "LKJHHM-L",
 				},
 			},
-			pubEnts: map[string]bool{
-				"MNBVCX-E": true,
+			pubEnts: map[string]string{
+				"MNBVCX-E": "US",
 			},
 			expected: map[string]rawFinancialInstrument{
 				"ABCDEF-S": {
@@ -279,6 +450,7 @@ func TestApplyPublicEntityFiltering(t *testing.T) {
 					fiType:           "EQ",
 					securityName:     "foobar INC",
 					primaryListingID: "LKJHHM-L",
+					issuerCountry:    "US",
 				},
 			},
 		},
@@ -300,8 +472,8 @@ func TestApplyPublicEntityFiltering(t *testing.T) {
 					primaryListingID: "LKJHHM-L",
 				},
 			},
-			pubEnts: map[string]bool{
-				"MNBVCX-E": true,
+			pubEnts: map[string]string{
+				"MNBVCX-E": "US",
 			},
 			expected: map[string]rawFinancialInstrument{
 				"ABCDEF-S": {
@@ -310,6 +482,7 @@ func TestApplyPublicEntityFiltering(t *testing.T) {
 					fiType:           "EQ",
 					securityName:     "foobar INC",
 					primaryListingID: "LKJHHM-L",
+					issuerCountry:    "US",
 				},
 			},
 		},
@@ -317,6 +490,9 @@ func TestApplyPublicEntityFiltering(t *testing.T) {
 
 	for _, tc := range tests {
 		applyPublicEntityFilter(tc.rawFIs, tc.pubEnts)
+		if !reflect.DeepEqual(tc.rawFIs, tc.expected) {
+			t.Errorf("Expected: [%v]. Actual: [%v]", tc.expected, tc.rawFIs)
+		}
 	}
 
 }
@@ -325,6 +501,7 @@ func TestTransformMappings(t *testing.T) {
 	var tests = []struct {
 		figisToSecIDs  map[string]string
 		secIDstoRawFIs map[string]rawFinancialInstrument
+		codes          map[string]listingCodes
 		expected       map[string]financialInstrument
 	}{
 		// edge cases
@@ -350,29 +527,205 @@ func TestTransformMappings(t *testing.T) {
 					fiType:           "EQ",
 					securityName:     "foobar INC",
 					primaryListingID: "LKJHHM-L",
+					exchange:         "NAS",
+					currency:         "USD",
+					issuerCountry:    "US",
+					listings: []listing{
+						{listingID: "QWERTY-L", exchange: "XET", currency: "EUR"},
+						{listingID: "LKJHHM-L", exchange: "NAS", currency: "USD", primary: true},
+					},
 				},
 			},
+			codes: map[string]listingCodes{
+				"LKJHHM-L": {figiCode: "BBG000123NMAV", ticker: "FOO US"},
+				"QWERTY-L": {figiCode: "BBG000123NMXE", ticker: "FOO GR"},
+			},
 			expected: map[string]financialInstrument{
 				"fd0d50ba-7031-3ebf-a594-4806b65a74bd": {
-					figiCode:     "BBG000123NMAV",
-					securityID:   "ABCDEF-S",
-					orgID:        "6f2a22e5-2fb6-304e-b92b-1438f306dc94",
-					securityName: "foobar INC",
+					figiCode:      "BBG000123NMAV",
+					securityID:    "ABCDEF-S",
+					orgID:         "6f2a22e5-2fb6-304e-b92b-1438f306dc94",
+					securityName:  "foobar INC",
+					ticker:        "FOO US",
+					exchange:      "NAS",
+					currency:      "USD",
+					issuerCountry: "US",
+					listings: []listing{
+						{listingID: "LKJHHM-L", figiCode: "BBG000123NMAV", ticker: "FOO US", exchange: "NAS", currency: "USD", primary: true},
+						{listingID: "QWERTY-L", figiCode: "BBG000123NMXE", ticker: "FOO GR", exchange: "XET", currency: "EUR"},
+					},
 				},
 			},
 		},
 	}
 
 	for _, tc := range tests {
-		tcM := fiMappings{tc.figisToSecIDs, tc.secIDstoRawFIs}
+		tcM := fiMappings{figiCodeToSecurityIDs: tc.figisToSecIDs, securityIDtoRawFinancialInstruments: tc.secIDstoRawFIs, listingIDToCodes: tc.codes}
 
-		fis := transformMappings(tcM)
+		fis, _ := transformMappings(tcM)
 		if !reflect.DeepEqual(fis, tc.expected) {
 			t.Errorf("Expected: [%v]. Actual: [%v]", tc.expected, fis)
 		}
 	}
 }
 
+func TestTransform_LocalDatasetWithDailies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fis_test_data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeLocalDataset(t, dir, "2017-08-10")
+	// IPM is renamed, M&S loses its issuer and Ralph Martindale goes public
+	writeLocalDaily(t, dir, "2017-08-14", map[string]string{
+		securities: `"FSYM_ID"|"CURRENCY"|"PROPER_NAME"|"FSYM_PRIMARY_EQUITY_ID"|"FSYM_PRIMARY_LISTING_ID"|"ACTIVE_FLAG"|"FREF_SECURITY_TYPE"|"FREF_LISTING_EXCHANGE"|"LISTING_FLAG"|"REGIONAL_FLAG"|"SECURITY_FLAG"|"FSYM_REGIONAL_ID"|"FSYM_SECURITY_ID"|"UNIVERSE_TYPE"` + "\n" +
+			`"JBP7Z8-S"|""|"Industrija Precizne Mehanike AD Beograd"|"JBP7Z8-S"|"WHV8G2-R"|1|"SHARE"|""|0|0|1|"WHV8G2-R"|"JBP7Z8-S"|"EQ"`,
+		securityEntityMap + deletesSuffix: `"FSYM_ID"` + "\n" +
+			`"GG9B0P-S"`,
+	})
+	writeLocalDaily(t, dir, "2017-08-15", map[string]string{
+		entities: `"FACTSET_ENTITY_ID"|"ENTITY_NAME"|"ENTITY_PROPER_NAME"|"PRIMARY_SIC_CODE"|"INDUSTRY_CODE"|"SECTOR_CODE"|"ISO_COUNTRY"|"METRO_AREA"|"STATE_PROVINCE"|"ZIP_POSTAL_CODE"|"WEB_SITE"|"ENTITY_TYPE"|"ENTITY_SUB_TYPE"|"YEAR_FOUNDED"|"ISO_COUNTRY_INCORP"|"ISO_COUNTRY_COR"|"NACE_CODE"` + "\n" +
+			`"007BPZ-E"|"RALPH MARTINDALE & COMPANY LTD"|"Ralph Martindale & Company Ltd"|""|""|""|"GB"|""|""|""|""|"PUB"|"CP"||"GB"|""|""`,
+	})
+
+	l := newFSLoader(dir)
+	fit := &fiTransformerImpl{loader: &l, parser: newFIParser(defaultSelectionRules)}
+
+	dailies, err := fit.findDailyFolders("2017-08-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := fit.Transform("2017-08-10", dailies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]financialInstrument{
+		"404c8329-3f8e-348e-ba32-cf3eb2c1ffed": {
+			figiCode:      "BBG000JPVHS1",
+			securityID:    "JBP7Z8-S",
+			orgID:         "ea90a425-73be-33c5-9aa4-939c9a46b87a",
+			securityName:  "Industrija Precizne Mehanike AD Beograd",
+			ticker:        "IPMB SG",
+			exchange:      "BEL",
+			currency:      "RSD",
+			issuerCountry: "RS",
+			listings: []listing{
+				{listingID: "M679DF-L", figiCode: "BBG000JPVHS1", ticker: "IPMB SG", exchange: "BEL", currency: "RSD", primary: true},
+			},
+		},
+		"23dc5c8b-412d-352f-8b46-b312265086de": {
+			figiCode:      "BBG000CXWV71",
+			securityID:    "K7TPSX-S",
+			orgID:         "34eca068-f264-3664-8f06-26ba9cb67ddc",
+			securityName:  "Ralph Martindale & Company Ltd",
+			ticker:        "RMC LN",
+			exchange:      "LON",
+			currency:      "GBP",
+			issuerCountry: "GB",
+			listings: []listing{
+				{listingID: "V0CJ4K-L", figiCode: "BBG000CXWV71", ticker: "RMC LN", exchange: "LON", currency: "GBP", primary: true},
+			},
+		},
+	}
+	if !reflect.DeepEqual(result.financialInstruments, expected) {
+		t.Errorf("Expected: [%v]. Actual: [%v]", expected, result.financialInstruments)
+	}
+	if !reflect.DeepEqual(result.report.DailyFolders, []string{"2017-08-14", "2017-08-15"}) {
+		t.Errorf("Unexpected daily folders in report: [%v]", result.report.DailyFolders)
+	}
+}
+
+func TestFindDailyFolders(t *testing.T) {
+	listings := 0
+	fit := &fiTransformerImpl{loader: &loaderMock{
+		mockListFolders: func() ([]string, []string, error) {
+			listings++
+			return []string{"2017-08-03", "2017-08-10", "2017-08-17"},
+				[]string{"2017-08-04", "2017-08-10", "2017-08-11", "2017-08-14", "2017-08-17", "2017-08-18"}, nil
+		},
+	}}
+
+	var testCases = []struct {
+		folder   string
+		expected []string
+	}{
+		{folder: "2017-08-03", expected: []string{"2017-08-04"}},
+		{folder: "2017-08-10", expected: []string{"2017-08-11", "2017-08-14"}},
+		{folder: "2017-08-17", expected: []string{"2017-08-18"}},
+	}
+
+	for _, tc := range testCases {
+		dailies, err := fit.findDailyFolders(tc.folder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dailies, tc.expected) {
+			t.Errorf("Folder [%s]. Expected: [%v]. Actual: [%v]", tc.folder, tc.expected, dailies)
+		}
+	}
+	if listings != len(testCases) {
+		t.Errorf("Expected the folders to be listed once per lookup. Actual listings: [%d]", listings)
+	}
+}
+
+func TestTransformMappings_SecurityWithSeveralFIGIs_LowestFIGIIsKept(t *testing.T) {
+	tcM := fiMappings{
+		figiCodeToSecurityIDs: map[string]string{
+			"BBG000123NMAV": "ABCDEF-S",
+			"BBG000123NMAA": "ABCDEF-S",
+			"BBG000123NMAZ": "ABCDEF-S",
+		},
+		securityIDtoRawFinancialInstruments: map[string]rawFinancialInstrument{
+			"ABCDEF-S": {
+				securityID:       "ABCDEF-S",
+				orgID:            "MNBVCX-E",
+				fiType:           "EQ",
+				securityName:     "foobar INC",
+				primaryListingID: "LKJHHM-L",
+			},
+		},
+	}
+
+	for i := 0; i < 10; i++ {
+		fis, conflicts := transformMappings(tcM)
+
+		expected := map[string]financialInstrument{
+			"fd0d50ba-7031-3ebf-a594-4806b65a74bd": {
+				figiCode:     "BBG000123NMAA",
+				securityID:   "ABCDEF-S",
+				orgID:        "6f2a22e5-2fb6-304e-b92b-1438f306dc94",
+				securityName: "foobar INC",
+			},
+		}
+		expectedConflicts := []figiConflict{
+			{
+				Reason: securityWithSeveralFIGIs,
+				Kept:   conflictRecord{FactsetID: "ABCDEF-S", FIGI: "BBG000123NMAA"},
+				Discarded: []conflictRecord{
+					{FactsetID: "ABCDEF-S", FIGI: "BBG000123NMAV"},
+					{FactsetID: "ABCDEF-S", FIGI: "BBG000123NMAZ"},
+				},
+			},
+		}
+		if !reflect.DeepEqual(fis, expected) {
+			t.Fatalf("Expected: [%v]. Actual: [%v]", expected, fis)
+		}
+		if !reflect.DeepEqual(conflicts, expectedConflicts) {
+			t.Fatalf("Expected: [%v]. Actual: [%v]", expectedConflicts, conflicts)
+		}
+	}
+
+	_, conflicts := transformMappings(tcM)
+	serialized, err := json.Marshal(conflicts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(serialized), `{"reason":"securityWithSeveralFIGIs",`) {
+		t.Errorf("Unexpected serialized conflict: [%s]", serialized)
+	}
+}
+
 func TestDoubleMD5Hash(t *testing.T) {
 	var testCases = []struct {
 		input    string